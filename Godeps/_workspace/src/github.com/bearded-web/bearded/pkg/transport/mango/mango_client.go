@@ -1,6 +1,8 @@
 package mango
 
 import (
+	"crypto/tls"
+
 	"github.com/bearded-web/bearded/pkg/transport"
 	"github.com/gdamore/mangos"
 	"golang.org/x/net/context"
@@ -9,6 +11,7 @@ import (
 type client struct {
 	addr string
 	sock mangos.Socket
+	opts map[string]interface{} // dialer options, e.g. tls config
 }
 
 func NewClient(addr string) (transport.Transport, error) {
@@ -23,13 +26,30 @@ func NewClient(addr string) (transport.Transport, error) {
 	return transport.NewLoopTransport(&s), nil
 }
 
+// NewClientWithConfig dials tls+tcp addresses with the tls config, e.g. with the client certificate,
+// the config is ignored by other transports and could be nil
+func NewClientWithConfig(addr string, config *tls.Config) (transport.Transport, error) {
+	sock, err := newSock()
+	if err != nil {
+		return nil, err
+	}
+	s := client{
+		addr: addr,
+		sock: sock,
+	}
+	if config != nil {
+		s.opts = map[string]interface{}{mangos.OptionTlsConfig: config}
+	}
+	return transport.NewLoopTransport(&s), nil
+}
+
 func (s *client) Loop(ctx context.Context,
 	in chan<- *transport.Message, out <-chan *transport.Message) <-chan error {
 	ch := make(chan error, 1)
 
 	go func(ch chan<- error) {
 		defer close(ch)
-		err := s.sock.Dial(s.addr)
+		err := s.sock.DialOptions(s.addr, s.opts)
 		if err != nil {
 			ch <- err
 			return
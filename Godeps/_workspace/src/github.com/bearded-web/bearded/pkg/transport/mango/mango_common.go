@@ -7,6 +7,7 @@ import (
 	"github.com/facebookgo/stackerr"
 	"github.com/gdamore/mangos"
 	"github.com/gdamore/mangos/protocol/pair"
+	"github.com/gdamore/mangos/transport/ipc"
 	"github.com/gdamore/mangos/transport/tcp"
 	"github.com/gdamore/mangos/transport/tlstcp"
 	"golang.org/x/net/context"
@@ -34,6 +35,7 @@ func newSock() (mangos.Socket, error) {
 		return nil, stackerr.Newf("can't get new pair socket: %s", err.Error())
 	}
 	sock.AddTransport(tcp.NewTransport())
+	sock.AddTransport(ipc.NewTransport())
 	sock.AddTransport(tlstcp.NewTransport())
 	if err != nil {
 		return nil, err
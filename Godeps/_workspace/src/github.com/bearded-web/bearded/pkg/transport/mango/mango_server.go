@@ -11,6 +11,7 @@ import (
 type server struct {
 	addr string
 	sock mangos.Socket
+	opts map[string]interface{} // listener options, e.g. tls config
 }
 
 func NewServer(addr string) (transport.Transport, error) {
@@ -36,10 +37,27 @@ func NewTlsServer(addr string, certFile, keyFile string) (transport.Transport, e
 	if err != nil {
 		return nil, err
 	}
-	sock.SetOption(mangos.OptionTlsConfig, config)
 	s := server{
 		addr: addr,
 		sock: sock,
+		opts: map[string]interface{}{mangos.OptionTlsConfig: config},
+	}
+	return transport.NewLoopTransport(&s), nil
+}
+
+// NewServerWithConfig listens with the tls config for tls+tcp addresses, e.g. to verify client certificates,
+// the config is ignored by other transports and could be nil
+func NewServerWithConfig(addr string, config *tls.Config) (transport.Transport, error) {
+	sock, err := newSock()
+	if err != nil {
+		return nil, err
+	}
+	s := server{
+		addr: addr,
+		sock: sock,
+	}
+	if config != nil {
+		s.opts = map[string]interface{}{mangos.OptionTlsConfig: config}
 	}
 	return transport.NewLoopTransport(&s), nil
 }
@@ -50,7 +68,7 @@ func (s *server) Loop(ctx context.Context,
 
 	go func(ch chan<- error) {
 		defer close(ch)
-		err := s.sock.Listen(s.addr)
+		err := s.sock.ListenOptions(s.addr, s.opts)
 		if err != nil {
 			ch <- err
 			return
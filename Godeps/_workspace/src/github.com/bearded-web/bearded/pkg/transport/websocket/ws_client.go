@@ -40,6 +40,15 @@ func NewTlsClient(addr string, certFile, keyFile string) (transport.Transport, e
 	return transport.NewLoopTransport(&s), nil
 }
 
+// NewClientWithConfig dials with the tls config, e.g. with the client certificate and custom roots
+func NewClientWithConfig(addr string, config *tls.Config) transport.Transport {
+	s := client{
+		addr:   addr,
+		dialer: &websocket.Dialer{TLSClientConfig: config},
+	}
+	return transport.NewLoopTransport(&s)
+}
+
 func (s *client) Loop(ctx context.Context,
 	in chan<- *transport.Message, out <-chan *transport.Message) <-chan error {
 	ch := make(chan error, 1)
@@ -9,6 +9,9 @@ import (
 
 func handleConnection(ctx context.Context, ws *websocket.Conn,
 	in chan<- *transport.Message, out <-chan *transport.Message) {
+	// stop the write loop when the connection is broken, so the next connection gets the messages
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	ch := make(chan error, 2)
 
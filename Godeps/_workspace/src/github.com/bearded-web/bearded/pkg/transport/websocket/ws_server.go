@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"crypto/tls"
 	"net/http"
 
 	"github.com/Sirupsen/logrus"
@@ -13,6 +14,7 @@ type server struct {
 	server            *http.Server
 	certFile, keyFile string
 	tls               bool
+	upgrader          *websocket.Upgrader
 }
 
 func NewServer(addr string) transport.Transport {
@@ -32,10 +34,24 @@ func NewTlsServer(addr, certFile, keyFile string) transport.Transport {
 	return transport.NewLoopTransport(&s)
 }
 
+// NewServerWithConfig serves with tls if the config is set, e.g. to verify client certificates,
+// upgrader checks origins of connections, the default one is used if it's nil
+func NewServerWithConfig(addr string, config *tls.Config, upgrader *websocket.Upgrader) transport.Transport {
+	s := server{
+		tls:      config != nil,
+		server:   &http.Server{Addr: addr, TLSConfig: config},
+		upgrader: upgrader,
+	}
+	return transport.NewLoopTransport(&s)
+}
+
 func (s *server) getHttpHandler(ctx context.Context,
 	in chan<- *transport.Message, out <-chan *transport.Message) http.HandlerFunc {
+	upgrader := s.upgrader
+	if upgrader == nil {
+		upgrader = &websocket.Upgrader{}
+	}
 	return func(resp http.ResponseWriter, req *http.Request) {
-		upgrader := websocket.Upgrader{}
 		ws, err := upgrader.Upgrade(resp, req, http.Header{})
 		if err != nil {
 			logrus.Error(err)
@@ -49,19 +65,25 @@ func (s *server) getHttpHandler(ctx context.Context,
 
 func (s *server) Loop(ctx context.Context,
 	in chan<- *transport.Message, out <-chan *transport.Message) <-chan error {
-	// http server will never stop. It's ok for scripts, because they died after execution (or will be killed =) )
-	// TODO (m0sth8): Implement cancel by context here (maybe after https://github.com/golang/go/issues/4674)
 	ch := make(chan error, 1)
 	s.server.Handler = s.getHttpHandler(ctx, in, out)
 
 	go func(ch chan<- error) {
+		defer close(ch)
+		// closed server stops serving and drops connections
+		go func() {
+			<-ctx.Done()
+			s.server.Close()
+		}()
 		var err error
 		if s.tls {
 			err = s.server.ListenAndServeTLS(s.certFile, s.keyFile)
 		} else {
 			err = s.server.ListenAndServe()
 		}
-		ch <- err
+		if err != http.ErrServerClosed {
+			ch <- err
+		}
 	}(ch)
 	return ch
 }
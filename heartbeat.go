@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/bearded-web/bearded/pkg/agent/api"
+	"github.com/bearded-web/bearded/pkg/script"
+	"github.com/bearded-web/bearded/pkg/transport"
+	"github.com/bearded-web/w3af-script/w3af"
+	"github.com/facebookgo/stackerr"
+	"golang.org/x/net/context"
+)
+
+const defaultReportTimeout = time.Minute
+
+type connConf struct {
+	ConnectTimeout time.Duration // how long to wait for the agent, 0 is forever
+	PingInterval   time.Duration // 0 disables heartbeat
+	DeadTimeout    time.Duration // the agent is dead if it doesn't answer pings for this time, reports wait for it
+}
+
+// heartbeat pings the agent and tracks if it's alive,
+// the dead agent doesn't stop the scan, the heartbeat keeps pinging to notice when it's back
+type heartbeat struct {
+	transp      transport.Transport
+	interval    time.Duration
+	deadTimeout time.Duration
+
+	mu        sync.Mutex
+	lastSeen  time.Time
+	failed    bool
+	recovered chan struct{}
+}
+
+func newHeartbeat(transp transport.Transport, conf *connConf) *heartbeat {
+	return &heartbeat{
+		transp:      transp,
+		interval:    conf.PingInterval,
+		deadTimeout: conf.DeadTimeout,
+		lastSeen:    time.Now(),
+		recovered:   make(chan struct{}),
+	}
+}
+
+// Run pings the agent until the context is done
+func (h *heartbeat) Run(ctx context.Context) {
+	reported := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(h.interval):
+		}
+		if h.ping(ctx) {
+			if reported {
+				logrus.Info("agent is back")
+				reported = false
+			}
+			continue
+		}
+		lastSeen := h.LastSeen()
+		logrus.WithField("lastSeen", lastSeen.Format(time.RFC3339)).Warn("agent didn't answer ping")
+		if !reported && h.Dead() {
+			logrus.WithField("deadTimeout", h.deadTimeout.String()).Error("agent is dead, the scan goes on and the report waits for it")
+			reported = true
+		}
+	}
+}
+
+// ping returns true if the agent answered, an error answer is fine too,
+// because agents which don't know ping are still alive
+func (h *heartbeat) ping(ctx context.Context) bool {
+	pingCtx, cancel := context.WithTimeout(ctx, h.interval)
+	defer cancel()
+	var resp interface{}
+	err := h.transp.Request(pingCtx, api.RequestV1{Method: api.Ping}, &resp)
+	alive := err == nil || pingCtx.Err() == nil
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !alive {
+		h.failed = true
+		return false
+	}
+	h.lastSeen = time.Now()
+	if h.failed {
+		h.failed = false
+		close(h.recovered)
+		h.recovered = make(chan struct{})
+	}
+	return true
+}
+
+// LastSeen returns time of the last answer from the agent
+func (h *heartbeat) LastSeen() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastSeen
+}
+
+// Dead returns true if the agent doesn't answer pings for the dead timeout
+func (h *heartbeat) Dead() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.failed && time.Since(h.lastSeen) > h.deadTimeout
+}
+
+// Recovered returns a channel which is closed when the agent answers after a failed ping
+func (h *heartbeat) Recovered() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.recovered
+}
+
+// bufferedClient keeps the report or file download while the agent is dead and makes one attempt
+// with a timeout when the agent is back, the agent could drop the request.
+// Unanswered calls are retried by w3af retry policy with backoff or as soon as the agent is back
+// until the retry deadline, the agent answers with errors aren't retried.
+type bufferedClient struct {
+	script.ClientV1
+	beat    *heartbeat
+	timeout time.Duration // timeout of one attempt
+}
+
+func (c *bufferedClient) SendReport(ctx context.Context, rep *report.Report) error {
+	return c.call(ctx, "take the report", func(ctx context.Context) error {
+		return c.ClientV1.SendReport(ctx, rep)
+	})
+}
+
+func (c *bufferedClient) DownloadFile(ctx context.Context, fileId string) ([]byte, error) {
+	var data []byte
+	err := c.call(ctx, fmt.Sprintf("send file %s", fileId), func(ctx context.Context) error {
+		var err error
+		data, err = c.ClientV1.DownloadFile(ctx, fileId)
+		return err
+	})
+	return data, err
+}
+
+// call waits for the dead agent and makes one attempt of f, action describes the agent's part in errors
+func (c *bufferedClient) call(ctx context.Context, action string, f func(ctx context.Context) error) error {
+	timeout := c.timeout
+	if timeout == 0 {
+		timeout = defaultReportTimeout
+	}
+	if c.beat != nil {
+		// the channel is taken before the check to not miss the recovery
+		recovered := c.beat.Recovered()
+		if c.beat.Dead() {
+			logrus.WithField("call", action).Warn("agent is dead, the call waits for it")
+			select {
+			case <-ctx.Done():
+				return stackerr.Newf("agent didn't come back: %s", ctx.Err())
+			case <-recovered:
+			}
+		}
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := f(sendCtx)
+	if err == nil {
+		return nil
+	}
+	// the agent has answered with an error, so it's not a connection problem
+	if sendCtx.Err() != context.DeadlineExceeded {
+		return &w3af.PermanentError{Err: stackerr.Wrap(err)}
+	}
+	return stackerr.Newf("agent didn't %s in %s: %s", action, timeout, err)
+}
+
+// Recovered implements w3af.Recoverer, the channel is nil without heartbeat
+func (c *bufferedClient) Recovered() <-chan struct{} {
+	if c.beat == nil {
+		return nil
+	}
+	return c.beat.Recovered()
+}
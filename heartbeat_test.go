@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/bearded-web/bearded/pkg/agent/api"
+	"github.com/bearded-web/bearded/pkg/script"
+	"github.com/bearded-web/bearded/pkg/transport"
+	"github.com/bearded-web/w3af-script/w3af"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// fakeAgentTransport answers pings while the agent is alive and drops requests otherwise
+type fakeAgentTransport struct {
+	mu    sync.Mutex
+	alive bool
+	// agent answers with an error, like the one which doesn't know ping
+	answerErr error
+	pings     int
+}
+
+func (f *fakeAgentTransport) Serve(ctx context.Context, h transport.Handler) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeAgentTransport) Request(ctx context.Context, send, recv interface{}) error {
+	f.mu.Lock()
+	if req, ok := send.(api.RequestV1); ok && req.Method == api.Ping {
+		f.pings++
+	}
+	alive, answerErr := f.alive, f.answerErr
+	f.mu.Unlock()
+	if alive {
+		return answerErr
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (f *fakeAgentTransport) SetAlive(alive bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.alive = alive
+}
+
+// flakyReportClient drops reports and downloads until the agent is alive
+type flakyReportClient struct {
+	script.FakeClient
+	transp *fakeAgentTransport
+
+	mu       sync.Mutex
+	attempts int
+	reports  []*report.Report
+}
+
+func (c *flakyReportClient) SendReport(ctx context.Context, rep *report.Report) error {
+	c.mu.Lock()
+	c.attempts++
+	c.mu.Unlock()
+	if err := c.transp.Request(ctx, api.RequestV1{Method: api.SendReport}, nil); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reports = append(c.reports, rep)
+	return nil
+}
+
+func (c *flakyReportClient) DownloadFile(ctx context.Context, fileId string) ([]byte, error) {
+	c.mu.Lock()
+	c.attempts++
+	c.mu.Unlock()
+	if err := c.transp.Request(ctx, api.RequestV1{Method: api.DownloadFile}, nil); err != nil {
+		return nil, err
+	}
+	return []byte(fileId), nil
+}
+
+func TestConnectTimeout(t *testing.T) {
+	port := freePort(t)
+	transp, err := newTransport(&transportConf{Addr: fmt.Sprintf("tcp://127.0.0.1:%d", port)})
+	require.NoError(t, err)
+	started := time.Now()
+	err = serve(context.Background(), transp, newTestW3af(t), &connConf{ConnectTimeout: 50 * time.Millisecond})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "agent didn't connect in 50ms")
+	assert.True(t, time.Since(started) < time.Second)
+}
+
+func TestHeartbeat(t *testing.T) {
+	transp := &fakeAgentTransport{alive: true}
+	beat := newHeartbeat(transp, &connConf{PingInterval: 10 * time.Millisecond, DeadTimeout: 50 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go beat.Run(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	transp.mu.Lock()
+	assert.True(t, transp.pings > 1)
+	transp.mu.Unlock()
+	assert.True(t, time.Since(beat.LastSeen()) < 50*time.Millisecond)
+
+	// the agent which doesn't know ping is alive
+	transp.mu.Lock()
+	transp.answerErr = fmt.Errorf("Unknown method requested Ping")
+	transp.mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, beat.Dead())
+
+	// the agent is gone
+	transp.SetAlive(false)
+	waitFor(t, beat.Dead, "dead agent isn't detected")
+
+	// the heartbeat goes on and notices that the agent is back
+	recovered := beat.Recovered()
+	transp.SetAlive(true)
+	select {
+	case <-recovered:
+	case <-time.After(time.Second):
+		t.Fatal("agent recovery isn't detected")
+	}
+	assert.False(t, beat.Dead())
+}
+
+func waitFor(t *testing.T, cond func() bool, msg string) {
+	for started := time.Now(); time.Since(started) < time.Second; time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal(msg)
+}
+
+func TestHeartbeatRecovered(t *testing.T) {
+	transp := &fakeAgentTransport{}
+	beat := newHeartbeat(transp, &connConf{PingInterval: 10 * time.Millisecond})
+	recovered := beat.Recovered()
+	assert.False(t, beat.ping(context.Background()))
+	transp.SetAlive(true)
+	assert.True(t, beat.ping(context.Background()))
+	select {
+	case <-recovered:
+	default:
+		t.Fatal("recovered channel isn't closed")
+	}
+	// next recovery has a new channel
+	assert.NotEqual(t, recovered, beat.Recovered())
+}
+
+func TestBufferedClientSendsReport(t *testing.T) {
+	transp := &fakeAgentTransport{}
+	client := &flakyReportClient{transp: transp}
+	buffered := &bufferedClient{ClientV1: client, timeout: 10 * time.Millisecond}
+
+	// the agent doesn't answer, the attempt is timed out
+	err := buffered.SendReport(context.Background(), &report.Report{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "agent didn't take the report in 10ms")
+	_, permanent := err.(*w3af.PermanentError)
+	assert.False(t, permanent)
+
+	// error answer isn't retried
+	transp.alive, transp.answerErr = true, fmt.Errorf("bad report")
+	err = buffered.SendReport(context.Background(), &report.Report{})
+	require.Error(t, err)
+	_, permanent = err.(*w3af.PermanentError)
+	assert.True(t, permanent)
+
+	transp.answerErr = nil
+	rep := &report.Report{Type: report.TypeEmpty}
+	require.NoError(t, buffered.SendReport(context.Background(), rep))
+	require.Len(t, client.reports, 1)
+	assert.Equal(t, rep, client.reports[0])
+	assert.Equal(t, 3, client.attempts)
+
+	// without heartbeat the agent never recovers
+	assert.Nil(t, buffered.Recovered())
+	buffered.beat = newHeartbeat(transp, &connConf{PingInterval: time.Second})
+	assert.NotNil(t, buffered.Recovered())
+}
+
+func TestBufferedClientDownloadsFile(t *testing.T) {
+	transp := &fakeAgentTransport{}
+	client := &flakyReportClient{transp: transp}
+	buffered := &bufferedClient{ClientV1: client, timeout: 10 * time.Millisecond}
+
+	// the agent doesn't answer, the download is retried by w3af
+	_, err := buffered.DownloadFile(context.Background(), "1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "agent didn't send file 1 in 10ms")
+	_, permanent := err.(*w3af.PermanentError)
+	assert.False(t, permanent)
+
+	// the agent doesn't have the file, the answer is the same on retries
+	transp.alive, transp.answerErr = true, fmt.Errorf("file not found")
+	_, err = buffered.DownloadFile(context.Background(), "1")
+	require.Error(t, err)
+	_, permanent = err.(*w3af.PermanentError)
+	assert.True(t, permanent)
+
+	transp.answerErr = nil
+	data, err := buffered.DownloadFile(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), data)
+	assert.Equal(t, 3, client.attempts)
+}
+
+func TestAgentDropsMidScan(t *testing.T) {
+	transp := &fakeAgentTransport{alive: true}
+	beat := newHeartbeat(transp, &connConf{PingInterval: 10 * time.Millisecond, DeadTimeout: 30 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go beat.Run(ctx)
+
+	// the agent drops while w3af is running
+	transp.SetAlive(false)
+	waitFor(t, beat.Dead, "dead agent isn't detected")
+	client := &flakyReportClient{transp: transp}
+	buffered := &bufferedClient{ClientV1: client, beat: beat, timeout: 10 * time.Millisecond}
+	done := make(chan error, 1)
+	go func() {
+		// invalid target makes the script send a report without running w3af
+		done <- newTestW3af(t).Handle(ctx, buffered, &plan.Conf{Target: "ftp://example.com"})
+	}()
+
+	// the report waits for the agent and isn't sent to the dead agent
+	time.Sleep(100 * time.Millisecond)
+	client.mu.Lock()
+	assert.Equal(t, 0, client.attempts)
+	client.mu.Unlock()
+	select {
+	case err := <-done:
+		t.Fatalf("scan is stopped by the dead agent: %v", err)
+	default:
+	}
+
+	// the agent is back and gets the report
+	transp.SetAlive(true)
+	require.NoError(t, <-done)
+	require.Len(t, client.reports, 1)
+	assert.Equal(t, report.TypeIssues, client.reports[0].Type)
+}
+
+func TestDeadAgentReportDeadline(t *testing.T) {
+	transp := &fakeAgentTransport{}
+	beat := newHeartbeat(transp, &connConf{PingInterval: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go beat.Run(ctx)
+	waitFor(t, beat.Dead, "dead agent isn't detected")
+
+	app, err := w3af.NewW3af(w3af.WithRetryDeadline(50 * time.Millisecond))
+	require.NoError(t, err)
+	client := &flakyReportClient{transp: transp}
+	started := time.Now()
+	err = app.Handle(ctx, &bufferedClient{ClientV1: client, beat: beat}, &plan.Conf{Target: "ftp://example.com"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "agent didn't come back")
+	assert.True(t, time.Since(started) < time.Second)
+	assert.Equal(t, 0, client.attempts)
+}
@@ -3,50 +3,192 @@ package main
 // Temporary file
 
 import (
-	"github.com/bearded-web/bearded/pkg/script"
-	"github.com/davecgh/go-spew/spew"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
 	"golang.org/x/net/context"
 
-	"github.com/bearded-web/bearded/pkg/transport/mango"
 	"github.com/bearded-web/w3af-script/w3af"
 )
 
-func run(addr string) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// exit codes of baseline and check commands
+const (
+	exitNewFindings = 1 // check found findings which aren't in the baseline
+	exitFailed      = 2 // the command failed, e.g. the report can't be read
+)
 
-	transp, err := mango.NewServer(addr)
-	if err != nil {
-		panic(err)
-	}
-	client, err := script.NewRemoteClient(transp)
-	if err != nil {
+func run(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	conf := &transportConf{}
+	flags.StringVar(&conf.Addr, "addr", env("SCRIPT_ADDR", defaultAddr),
+		"listen address: tcp://, ipc://, tls+tcp:// for mangos or ws://, wss:// for websocket")
+	flags.StringVar(&conf.CertFile, "cert", env("SCRIPT_CERT", ""), "path to tls certificate")
+	flags.StringVar(&conf.KeyFile, "key", env("SCRIPT_KEY", ""), "path to tls key")
+	flags.StringVar(&conf.CaFile, "ca", env("SCRIPT_CA", ""), "path to ca certificate which signs agent certificates")
+	connConf := &connConf{}
+	flags.DurationVar(&connConf.ConnectTimeout, "connect-timeout", 5*time.Minute, "how long to wait for the agent, 0 is forever")
+	flags.DurationVar(&connConf.PingInterval, "ping-interval", 30*time.Second, "agent heartbeat interval, 0 disables heartbeat")
+	flags.DurationVar(&connConf.DeadTimeout, "dead-timeout", 10*time.Minute,
+		"the agent is dead if it doesn't answer for this time, the scan goes on and the report waits for the agent")
+	reportDeadline := flags.Duration("report-deadline", 30*time.Minute,
+		"give up if the agent doesn't take the report for this time")
+	logFormat := flags.String("log-format", env("SCRIPT_LOG_FORMAT", "text"), "log format: text or json")
+	logLevel := flags.String("log-level", env("SCRIPT_LOG_LEVEL", "info"), "log level: debug, info, warning, error")
+	w3afConf := w3af.DefaultConfig()
+	flags.StringVar(&w3afConf.Tool, "w3af-tool", env("SCRIPT_W3AF_TOOL", w3afConf.Tool), "w3af plugin name")
+	flags.StringVar(&w3afConf.Version, "w3af-version", env("SCRIPT_W3AF_VERSION", ""),
+		"w3af plugin version, 1.6.* matches by prefix, the latest version is used if empty")
+	flags.StringVar(&w3afConf.HomeDir, "w3af-home", env("SCRIPT_W3AF_HOME", w3afConf.HomeDir),
+		"w3af home dir in the container, xml report is written here")
+	flags.StringVar(&w3afConf.ShareDir, "w3af-share", env("SCRIPT_W3AF_SHARE", w3afConf.ShareDir),
+		"dir with shared files in the container")
+	flags.StringVar(&w3afConf.ReportName, "w3af-report", env("SCRIPT_W3AF_REPORT", w3afConf.ReportName), "xml report name")
+	flags.StringVar(&w3afConf.ProfileName, "w3af-profile", env("SCRIPT_W3AF_PROFILE", w3afConf.ProfileName), "profile name")
+	w3afArgs := flags.String("w3af-args", env("SCRIPT_W3AF_ARGS", ""), "extra w3af command args separated by spaces")
+	metricsAddr := flags.String("metrics-addr", env("SCRIPT_METRICS_ADDR", ""),
+		"address to serve prometheus metrics on /metrics, metrics are disabled if it's empty")
+	flags.Parse(args)
+
+	if err := setupLog(*logFormat, *logLevel); err != nil {
 		panic(err)
 	}
-	go func() {
-		err := transp.Serve(ctx, client)
+	if *metricsAddr != "" {
+		addr, err := serveMetrics(*metricsAddr)
 		if err != nil {
-			panic(err)
+			logrus.WithField("error", err.Error()).Fatal("metrics server failed")
 		}
-	}()
-	println("wait for connection")
-	client.WaitForConnection(ctx)
-	println("request config")
-	conf, err := client.GetConfig(ctx)
+		logrus.WithField("addr", addr).Info("serve metrics")
+	}
+	transp, err := newTransport(conf)
 	if err != nil {
 		panic(err)
 	}
+	app, err := w3af.NewW3af(
+		w3af.WithTool(w3afConf.Tool, w3afConf.Version),
+		w3af.WithDirs(w3afConf.HomeDir, w3afConf.ShareDir),
+		w3af.WithFileNames(w3afConf.ReportName, w3afConf.ProfileName),
+		w3af.WithExtraArgs(strings.Fields(*w3afArgs)...),
+		w3af.WithRetryDeadline(*reportDeadline),
+	)
+	if err != nil {
+		logrus.WithField("error", err.Error()).Fatal("bad w3af config")
+	}
+	if err := serve(context.Background(), transp, app, connConf); err != nil {
+		logrus.WithField("error", err.Error()).Fatal("script failed")
+	}
+}
 
-	app := w3af.NewW3af()
+func setupLog(format, level string) error {
+	switch format {
+	case "text":
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %s", format)
+	}
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(lvl)
+	return nil
+}
 
-	println("handle with conf", spew.Sdump(conf))
-	err = app.Handle(ctx, client, conf)
+// env returns environment variable or the default value if it's empty
+func env(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// baseline generates baseline from w3af report.xml
+func baseline(args []string) {
+	flags := flag.NewFlagSet("baseline", flag.ExitOnError)
+	reportPath := flags.String("report", "report.xml", "path to w3af xml report")
+	out := flags.String("out", "", "path to baseline file, stdout by default")
+	justification := flags.String("justification", "accepted", "justification for all findings")
+	flags.Parse(args)
+
+	data, err := ioutil.ReadFile(*reportPath)
 	if err != nil {
-		panic(err)
+		fail(err)
+	}
+	b, err := w3af.GenerateBaseline(data, *justification)
+	if err != nil {
+		fail(err)
 	}
+	raw, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		fail(err)
+	}
+	if *out == "" {
+		fmt.Println(string(raw))
+		return
+	}
+	if err := ioutil.WriteFile(*out, raw, 0644); err != nil {
+		fail(err)
+	}
+}
+
+// check prints findings from w3af report.xml which aren't in the baseline and exits with exitNewFindings if there are any
+func check(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	reportPath := flags.String("report", "report.xml", "path to w3af xml report")
+	baselinePath := flags.String("baseline", "baseline.json", "path to baseline file")
+	flags.Parse(args)
+
+	data, err := ioutil.ReadFile(*reportPath)
+	if err != nil {
+		fail(err)
+	}
+	baselineData, err := ioutil.ReadFile(*baselinePath)
+	if err != nil {
+		fail(err)
+	}
+	b, err := w3af.ParseBaseline(baselineData)
+	if err != nil {
+		fail(err)
+	}
+	issues, err := w3af.CheckBaseline(data, b)
+	if err != nil {
+		fail(err)
+	}
+	for _, issueObj := range issues {
+		url := ""
+		if issueObj.Vector != nil {
+			url = issueObj.Vector.Url
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", issueObj.UniqId, issueObj.Severity, issueObj.Summary, url)
+	}
+	if len(issues) > 0 {
+		os.Exit(exitNewFindings)
+	}
+}
+
+// fail prints the error of baseline or check commands and exits with exitFailed
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	os.Exit(exitFailed)
 }
 
 func main() {
-	run("tcp://:9238")
-	//	run(":9238")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "baseline":
+			baseline(os.Args[2:])
+			return
+		case "check":
+			check(os.Args[2:])
+			return
+		}
+	}
+	run(os.Args[1:])
 }
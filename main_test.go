@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupLog(t *testing.T) {
+	defer logrus.SetFormatter(&logrus.TextFormatter{})
+	defer logrus.SetLevel(logrus.InfoLevel)
+
+	assert.NoError(t, setupLog("json", "debug"))
+	assert.IsType(t, &logrus.JSONFormatter{}, logrus.StandardLogger().Formatter)
+	assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+
+	assert.Error(t, setupLog("xml", "info"))
+	assert.Error(t, setupLog("text", "loud"))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/bearded-web/bearded/pkg/agent/api"
+	"github.com/bearded-web/bearded/pkg/transport"
+	"github.com/facebookgo/stackerr"
+	"golang.org/x/net/context"
+
+	"github.com/bearded-web/w3af-script/metrics"
+)
+
+// methodNames are used instead of api.Method.String, which doesn't know the latest methods
+var methodNames = map[api.Method]string{
+	api.Ping:              "Ping",
+	api.Connect:           "Connect",
+	api.GetConfig:         "GetConfig",
+	api.GetPluginVersions: "GetPluginVersions",
+	api.RunPlugin:         "RunPlugin",
+	api.SendReport:        "SendReport",
+	api.DownloadFile:      "DownloadFile",
+}
+
+var agentRequestDuration = metrics.NewHistogram("w3af_script_agent_request_duration_seconds",
+	"Latency of requests to the agent by method and status.", metrics.DefaultBuckets, "method", "status")
+
+// instrumentedTransport measures requests to the agent
+type instrumentedTransport struct {
+	transport.Transport
+}
+
+func (t *instrumentedTransport) Request(ctx context.Context, send, recv interface{}) error {
+	method := "unknown"
+	switch req := send.(type) {
+	case api.RequestV1:
+		method = methodName(req.Method)
+	case *api.RequestV1:
+		method = methodName(req.Method)
+	}
+	started := time.Now()
+	err := t.Transport.Request(ctx, send, recv)
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	agentRequestDuration.Observe(time.Since(started).Seconds(), method, status)
+	return err
+}
+
+func methodName(method api.Method) string {
+	if name, ok := methodNames[method]; ok {
+		return name
+	}
+	return method.String()
+}
+
+// serveMetrics serves metrics on /metrics in background and returns the listening address
+func serveMetrics(addr string) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", stackerr.Wrap(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			logrus.WithField("error", err.Error()).Error("metrics server failed")
+		}
+	}()
+	return ln.Addr().String(), nil
+}
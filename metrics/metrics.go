@@ -0,0 +1,274 @@
+// Package metrics is a tiny registry of counters and histograms exposed in prometheus text format
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	// DefaultBuckets are for durations in seconds
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// ScanBuckets are for w3af runs, which take from seconds to hours
+	ScanBuckets = []float64{10, 30, 60, 300, 600, 1800, 3600, 7200, 14400}
+	// SizeBuckets are for sizes in bytes
+	SizeBuckets = []float64{1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20, 100 << 20}
+)
+
+// DefaultRegistry is used by package level constructors and Handler
+var DefaultRegistry = NewRegistry()
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry keeps metrics in registration order
+type Registry struct {
+	mu      sync.Mutex
+	names   map[string]bool
+	metrics []metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("metric %s is already registered", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// NewCounter registers a counter with label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, labels), values: map[string]float64{}}
+	r.register(name, c)
+	return c
+}
+
+// NewHistogram registers a histogram with upper bounds of buckets and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{vec: newVec(name, help, labels), buckets: buckets, values: map[string]*histogramValue{}}
+	r.register(name, h)
+	return h
+}
+
+// WriteTo writes all metrics in prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mu.Unlock()
+	buf := &bytes.Buffer{}
+	for _, m := range metrics {
+		m.write(buf)
+	}
+	return buf.WriteTo(w)
+}
+
+// Handler serves metrics for scrapers
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		r.WriteTo(w)
+	})
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// vec is a common part of metrics with labels,
+// values are kept by label values joined with a zero byte
+type vec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu   sync.Mutex
+	keys map[string][]string
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{name: name, help: help, labels: labels, keys: map[string][]string{}}
+}
+
+// key returns values key, missing label values are empty and extra ones are ignored
+func (v *vec) key(labelValues []string) string {
+	values := make([]string, len(v.labels))
+	copy(values, labelValues)
+	return strings.Join(values, "\x00")
+}
+
+// add remembers label values of a new key, should be called under lock
+func (v *vec) add(labelValues []string) string {
+	key := v.key(labelValues)
+	if _, ok := v.keys[key]; !ok {
+		values := make([]string, len(v.labels))
+		copy(values, labelValues)
+		v.keys[key] = values
+	}
+	return key
+}
+
+// sortedKeys should be called under lock
+func (v *vec) sortedKeys() []string {
+	keys := make([]string, 0, len(v.keys))
+	for key := range v.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (v *vec) header(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, typ)
+}
+
+// labelPairs formats labels with extra pairs like le for histogram buckets
+func (v *vec) labelPairs(key string, extra ...string) string {
+	pairs := []string{}
+	for i, value := range v.keys[key] {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, v.labels[i], escapeLabel(value)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter only goes up
+type Counter struct {
+	vec
+	values map[string]float64
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter, negative values are ignored
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[c.add(labelValues)] += v
+}
+
+// Value returns current value for label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[c.key(labelValues)]
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w, "counter")
+	for _, key := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(key), formatFloat(c.values[key]))
+	}
+}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	vec
+	buckets []float64
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64 // not cumulative, the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := h.add(labelValues)
+	value, ok := h.values[key]
+	if !ok {
+		value = &histogramValue{counts: make([]uint64, len(h.buckets)+1)}
+		h.values[key] = value
+	}
+	i := sort.SearchFloat64s(h.buckets, v)
+	value.counts[i]++
+	value.sum += v
+	value.count++
+}
+
+// Count returns number of observations for label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if value, ok := h.values[h.key(labelValues)]; ok {
+		return value.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w, "histogram")
+	for _, key := range h.sortedKeys() {
+		value, ok := h.values[key]
+		if !ok {
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += value.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", "+Inf"), value.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(key), formatFloat(value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(key), value.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
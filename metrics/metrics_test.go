@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, r *Registry) (string, string) {
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body), resp.Header.Get("Content-Type")
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("issues_total", "Issues by severity.", "severity", "plugin")
+	c.Inc("high", "sqli")
+	c.Add(2, "high", "sqli")
+	c.Inc("low", `a"b\c`)
+	c.Add(-1, "low", `a"b\c`)
+	assert.Equal(t, 3.0, c.Value("high", "sqli"))
+	assert.Equal(t, 0.0, c.Value("medium", "xss"))
+
+	body, typ := scrape(t, r)
+	assert.Equal(t, contentType, typ)
+	assert.Equal(t, `# HELP issues_total Issues by severity.
+# TYPE issues_total counter
+issues_total{severity="high",plugin="sqli"} 3
+issues_total{severity="low",plugin="a\"b\\c"} 1
+`, body)
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("duration_seconds", "Duration.", []float64{1, 5})
+	h.Observe(0.5)
+	h.Observe(1)
+	h.Observe(10)
+	assert.Equal(t, uint64(3), h.Count())
+
+	body, _ := scrape(t, r)
+	assert.Equal(t, `# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{le="1"} 2
+duration_seconds_bucket{le="5"} 2
+duration_seconds_bucket{le="+Inf"} 3
+duration_seconds_sum 11.5
+duration_seconds_count 3
+`, body)
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("total", "")
+	assert.Panics(t, func() { r.NewCounter("total", "") })
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bearded-web/bearded/pkg/agent/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+
+	"github.com/bearded-web/w3af-script/metrics"
+)
+
+func TestInstrumentedTransport(t *testing.T) {
+	transp := &instrumentedTransport{Transport: &fakeAgentTransport{alive: true}}
+	ok := agentRequestDuration.Count("GetConfig", "ok")
+	failed := agentRequestDuration.Count("SendReport", "error")
+
+	var resp interface{}
+	require.NoError(t, transp.Request(context.Background(), api.RequestV1{Method: api.GetConfig}, &resp))
+	transp.Transport.(*fakeAgentTransport).answerErr = fmt.Errorf("bad report")
+	require.Error(t, transp.Request(context.Background(), &api.RequestV1{Method: api.SendReport}, &resp))
+	assert.Equal(t, ok+1, agentRequestDuration.Count("GetConfig", "ok"))
+	assert.Equal(t, failed+1, agentRequestDuration.Count("SendReport", "error"))
+
+	srv := httptest.NewServer(metrics.Handler())
+	defer srv.Close()
+	body := scrape(t, srv.URL)
+	assert.Contains(t, body, `w3af_script_agent_request_duration_seconds_count{method="GetConfig",status="ok"} `)
+	assert.Contains(t, body, `w3af_script_agent_request_duration_seconds_count{method="SendReport",status="error"} `)
+	// w3af metrics are registered too
+	assert.Contains(t, body, "# TYPE w3af_script_scans_started_total counter\n")
+}
+
+func TestServeMetrics(t *testing.T) {
+	addr, err := serveMetrics("127.0.0.1:0")
+	require.NoError(t, err)
+	body := scrape(t, "http://"+addr+"/metrics")
+	assert.Contains(t, body, "# TYPE w3af_script_agent_request_duration_seconds histogram\n")
+	_, err = serveMetrics(addr)
+	require.Error(t, err)
+}
+
+func scrape(t *testing.T, url string) string {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/bearded-web/bearded/pkg/script"
+	"github.com/bearded-web/bearded/pkg/transport"
+	"github.com/bearded-web/bearded/pkg/transport/mango"
+	"github.com/bearded-web/bearded/pkg/transport/websocket"
+	"github.com/facebookgo/stackerr"
+	gorilla "github.com/gorilla/websocket"
+	"golang.org/x/net/context"
+
+	"github.com/bearded-web/w3af-script/w3af"
+)
+
+const defaultAddr = "tcp://:9238"
+
+type transportConf struct {
+	// tcp://, ipc:// and tls+tcp:// addresses use mangos, ws:// and wss:// use websocket
+	Addr     string
+	CertFile string
+	KeyFile  string
+	CaFile   string // agent certificates are verified with this ca
+}
+
+// newTransport returns server transport chosen by the address scheme
+func newTransport(conf *transportConf) (transport.Transport, error) {
+	u, err := url.Parse(conf.Addr)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	var tlsConfig *tls.Config
+	if u.Scheme == "tls+tcp" || u.Scheme == "wss" {
+		if tlsConfig, err = conf.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+	switch u.Scheme {
+	case "tcp", "ipc", "tls+tcp":
+		return mango.NewServerWithConfig(conf.Addr, tlsConfig)
+	case "ws", "wss":
+		return websocket.NewServerWithConfig(u.Host, tlsConfig, &gorilla.Upgrader{CheckOrigin: agentOrigin}), nil
+	}
+	return nil, stackerr.Newf("unknown transport %s, use tcp, ipc, tls+tcp, ws or wss", u.Scheme)
+}
+
+// tlsConfig loads tls materials, agents without a certificate signed by the ca are rejected
+func (c *transportConf) tlsConfig() (*tls.Config, error) {
+	missing := []string{}
+	if c.CertFile == "" {
+		missing = append(missing, "certificate")
+	}
+	if c.KeyFile == "" {
+		missing = append(missing, "key")
+	}
+	if c.CaFile == "" {
+		missing = append(missing, "ca")
+	}
+	if len(missing) > 0 {
+		return nil, stackerr.Newf("tls transport requires %s", strings.Join(missing, ", "))
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	caData, err := ioutil.ReadFile(c.CaFile)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, stackerr.Newf("no certificates found in ca file %s", c.CaFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// serve waits for the agent, requests config and handles it with the app
+func serve(ctx context.Context, transp transport.Transport, app *w3af.W3af, conf *connConf) error {
+	transp = &instrumentedTransport{Transport: transp}
+	client, err := script.NewRemoteClient(transp)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- transp.Serve(ctx, client)
+	}()
+	logrus.Info("wait for connection")
+	waitCtx := ctx
+	if conf.ConnectTimeout > 0 {
+		var cancelWait context.CancelFunc
+		waitCtx, cancelWait = context.WithTimeout(ctx, conf.ConnectTimeout)
+		defer cancelWait()
+	}
+	connected := make(chan error, 1)
+	go func() {
+		connected <- client.WaitForConnection(waitCtx)
+	}()
+	select {
+	case err := <-serveErr:
+		if err == nil {
+			err = fmt.Errorf("transport is closed")
+		}
+		return stackerr.Wrap(err)
+	case err := <-connected:
+		if err == context.DeadlineExceeded {
+			return stackerr.Newf("agent didn't connect in %s", conf.ConnectTimeout)
+		}
+		if err != nil {
+			return stackerr.Wrap(err)
+		}
+	}
+	var beat *heartbeat
+	if conf.PingInterval > 0 {
+		beat = newHeartbeat(transp, conf)
+		go beat.Run(ctx)
+	}
+	logrus.Debug("request config")
+	scanConf, err := client.GetConfig(ctx)
+	if err == nil {
+		logrus.WithField("conf", confField(scanConf)).Info("handle config")
+		err = app.Handle(ctx, &bufferedClient{ClientV1: client, beat: beat}, scanConf)
+	}
+	return stackerr.Wrap(err)
+}
+
+// confField returns config without secrets for logs
+func confField(conf *plan.Conf) string {
+	data, err := json.Marshal(w3af.SanitizeConf(conf))
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// agentOrigin rejects websocket connections from browsers, agents don't send the origin header,
+// so a page opened in the browser on the script host can't talk to the script
+func agentOrigin(r *http.Request) bool {
+	return r.Header.Get("Origin") == ""
+}
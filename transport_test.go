@@ -0,0 +1,347 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/bearded-web/bearded/pkg/agent/api"
+	"github.com/bearded-web/bearded/pkg/transport"
+	"github.com/bearded-web/bearded/pkg/transport/mango"
+	"github.com/bearded-web/bearded/pkg/transport/websocket"
+	gorilla "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+
+	"github.com/bearded-web/w3af-script/w3af"
+)
+
+// fakeAgent answers script requests like bearded agent does
+type fakeAgent struct {
+	conf    *plan.Conf
+	reports chan *report.Report
+}
+
+func newFakeAgent() *fakeAgent {
+	return &fakeAgent{
+		// invalid target makes the script send a report without running w3af
+		conf:    &plan.Conf{Target: "ftp://example.com"},
+		reports: make(chan *report.Report, 1),
+	}
+}
+
+func (a *fakeAgent) Handle(ctx context.Context, msg transport.Extractor) (interface{}, error) {
+	req := api.RequestV1{}
+	if err := msg.Extract(&req); err != nil {
+		return nil, err
+	}
+	switch req.Method {
+	case api.GetConfig:
+		return api.ResponseV1{GetConfig: a.conf}, nil
+	case api.SendReport:
+		a.reports <- req.SendReport
+		return api.ResponseV1{}, nil
+	}
+	return nil, fmt.Errorf("unknown method %s", req.Method)
+}
+
+// testPki issues certificates signed by the generated ca
+type testPki struct {
+	dir    string
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	caFile string
+	serial int64
+}
+
+func newTestPki(t *testing.T, dir, name string) *testPki {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	p := &testPki{dir: dir, ca: ca, caKey: key, caFile: filepath.Join(dir, name+".crt"), serial: 1}
+	writePem(t, p.caFile, "CERTIFICATE", der)
+	return p
+}
+
+// issue returns certificate and key files and the loaded certificate
+func (p *testPki) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (string, string, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p.serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(p.serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, p.ca, &key.PublicKey, p.caKey)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(p.dir, name+".crt"), filepath.Join(p.dir, name+".key")
+	writePem(t, certFile, "CERTIFICATE", der)
+	writePem(t, keyFile, "EC PRIVATE KEY", keyDer)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	return certFile, keyFile, cert
+}
+
+func (p *testPki) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(p.ca)
+	return pool
+}
+
+func writePem(t *testing.T, filename, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, ioutil.WriteFile(filename, data, 0600))
+}
+
+// testTls returns the script transport config and the agent tls config
+func testTls(t *testing.T, dir, addr string) (*transportConf, *tls.Config, *testPki) {
+	pki := newTestPki(t, dir, "ca")
+	certFile, keyFile, _ := pki.issue(t, "script", x509.ExtKeyUsageServerAuth)
+	_, _, agentCert := pki.issue(t, "agent", x509.ExtKeyUsageClientAuth)
+	conf := &transportConf{Addr: addr, CertFile: certFile, KeyFile: keyFile, CaFile: pki.caFile}
+	agentTls := &tls.Config{
+		Certificates: []tls.Certificate{agentCert},
+		RootCAs:      pki.roots(),
+		ServerName:   "127.0.0.1",
+	}
+	return conf, agentTls, pki
+}
+
+// assertRejected checks that the script doesn't talk to an agent with the tls config
+func assertRejected(t *testing.T, port int, config *tls.Config) {
+	conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port), config)
+	if err == nil {
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		// tls 1.3 client finishes the handshake before the server checks the certificate
+		_, err = conn.Read(make([]byte, 1))
+	}
+	assert.Error(t, err)
+}
+
+func newTestW3af(t *testing.T) *w3af.W3af {
+	app, err := w3af.NewW3af()
+	require.NoError(t, err)
+	return app
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func waitForPort(port int) {
+	for i := 0; i < 100; i++ {
+		conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// testEndToEnd runs the script with the server transport and the agent with the client transport
+func testEndToEnd(t *testing.T, conf *transportConf, port int, agentTransport func() transport.Transport) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	transp, err := newTransport(conf)
+	require.NoError(t, err)
+	app := newTestW3af(t)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, transp, app, &connConf{})
+	}()
+	if port > 0 {
+		waitForPort(port)
+	}
+
+	agent := newFakeAgent()
+	agentTransp := agentTransport()
+	go agentTransp.Serve(ctx, agent)
+	var resp interface{}
+	require.NoError(t, agentTransp.Request(ctx, api.RequestV1{Method: api.Connect}, &resp))
+
+	select {
+	case rep := <-agent.reports:
+		assert.Equal(t, report.TypeIssues, rep.Type)
+		require.Len(t, rep.Issues, 1)
+		assert.Equal(t, issue.SeverityError, rep.Issues[0].Severity)
+	case <-ctx.Done():
+		t.Fatal("report wasn't sent")
+	}
+	require.NoError(t, <-done)
+}
+
+func TestEndToEndMangosTcp(t *testing.T) {
+	port := freePort(t)
+	addr := fmt.Sprintf("tcp://127.0.0.1:%d", port)
+	testEndToEnd(t, &transportConf{Addr: addr}, port, func() transport.Transport {
+		transp, err := mango.NewClient(addr)
+		require.NoError(t, err)
+		return transp
+	})
+}
+
+func TestEndToEndMangosIpc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "w3af-script")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	addr := "ipc://" + filepath.Join(dir, "script.sock")
+	testEndToEnd(t, &transportConf{Addr: addr}, 0, func() transport.Transport {
+		transp, err := mango.NewClient(addr)
+		require.NoError(t, err)
+		return transp
+	})
+}
+
+func TestEndToEndWebsocket(t *testing.T) {
+	port := freePort(t)
+	testEndToEnd(t, &transportConf{Addr: fmt.Sprintf("ws://127.0.0.1:%d", port)}, port, func() transport.Transport {
+		return websocket.NewClient(fmt.Sprintf("ws://127.0.0.1:%d/", port))
+	})
+}
+
+func TestWebsocketRejectsBrowsers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	port := freePort(t)
+	transp, err := newTransport(&transportConf{Addr: fmt.Sprintf("ws://127.0.0.1:%d", port)})
+	require.NoError(t, err)
+	go serve(ctx, transp, newTestW3af(t), &connConf{})
+	waitForPort(port)
+
+	// a page opened in the browser sends its origin
+	header := http.Header{"Origin": []string{fmt.Sprintf("http://127.0.0.1:%d", port)}}
+	_, resp, err := gorilla.DefaultDialer.Dial(fmt.Sprintf("ws://127.0.0.1:%d/", port), header)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestNewTransport(t *testing.T) {
+	_, err := newTransport(&transportConf{Addr: "udp://:9238"})
+	assert.Error(t, err)
+}
+
+func TestEndToEndMangosTls(t *testing.T) {
+	dir, err := ioutil.TempDir("", "w3af-script")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	port := freePort(t)
+	addr := fmt.Sprintf("tls+tcp://127.0.0.1:%d", port)
+	conf, agentTls, _ := testTls(t, dir, addr)
+	testEndToEnd(t, conf, port, func() transport.Transport {
+		transp, err := mango.NewClientWithConfig(addr, agentTls)
+		require.NoError(t, err)
+		return transp
+	})
+}
+
+func TestEndToEndWss(t *testing.T) {
+	dir, err := ioutil.TempDir("", "w3af-script")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	port := freePort(t)
+	conf, agentTls, _ := testTls(t, dir, fmt.Sprintf("wss://127.0.0.1:%d", port))
+	testEndToEnd(t, conf, port, func() transport.Transport {
+		return websocket.NewClientWithConfig(fmt.Sprintf("wss://127.0.0.1:%d/", port), agentTls)
+	})
+}
+
+func TestTlsRejectsUnknownAgents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "w3af-script")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, scheme := range []string{"tls+tcp", "wss"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		port := freePort(t)
+		conf, agentTls, pki := testTls(t, dir, fmt.Sprintf("%s://127.0.0.1:%d", scheme, port))
+		transp, err := newTransport(conf)
+		require.NoError(t, err)
+		go serve(ctx, transp, newTestW3af(t), &connConf{})
+		waitForPort(port)
+
+		// without client certificate
+		assertRejected(t, port, &tls.Config{RootCAs: pki.roots(), ServerName: "127.0.0.1"})
+		// certificate is signed by another ca
+		other := newTestPki(t, dir, "other-ca")
+		_, _, otherCert := other.issue(t, "intruder", x509.ExtKeyUsageClientAuth)
+		assertRejected(t, port, &tls.Config{
+			Certificates: []tls.Certificate{otherCert},
+			RootCAs:      pki.roots(),
+			ServerName:   "127.0.0.1",
+		})
+		// trusted agent is fine
+		conn, err := tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port), agentTls)
+		if assert.NoError(t, err, scheme) {
+			conn.Close()
+		}
+		cancel()
+	}
+}
+
+func TestTlsMissingMaterials(t *testing.T) {
+	for _, addr := range []string{"tls+tcp://:9238", "wss://:9238"} {
+		_, err := newTransport(&transportConf{Addr: addr})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "tls transport requires certificate, key, ca")
+
+		_, err = newTransport(&transportConf{Addr: addr, CertFile: "script.crt", KeyFile: "script.key"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "tls transport requires ca")
+	}
+	// files don't exist
+	_, err := newTransport(&transportConf{Addr: "wss://:9238", CertFile: "missing.crt", KeyFile: "missing.key", CaFile: "ca.crt"})
+	assert.Error(t, err)
+}
+
+func TestConfField(t *testing.T) {
+	field := confField(&plan.Conf{
+		Target:   "http://example.com",
+		FormData: `{"backend": "api", "api": {"url": "http://127.0.0.1:5000", "password": "hunter2"}}`,
+	})
+	assert.Contains(t, field, "http://example.com")
+	assert.Contains(t, field, "[REDACTED]")
+	assert.NotContains(t, field, "hunter2")
+}
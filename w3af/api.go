@@ -0,0 +1,378 @@
+package w3af
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/facebookgo/stackerr"
+	"golang.org/x/net/context"
+)
+
+// execution backends
+const (
+	BackendUtil = "util" // run w3af console as a plugin with type:util
+	BackendApi  = "api"  // use w3af REST API (w3af_api)
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	// time to stop and remove the scan after it's finished or cancelled
+	cleanupTimeout = time.Minute
+)
+
+type apiConf struct {
+	Url          string `json:"url"` // e.g. http://127.0.0.1:5000
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	PollInterval int    `json:"pollInterval"` // in seconds, 5 by default
+}
+
+// w3af_api responses, see w3af/core/ui/api/resources
+
+type apiScanCreated struct {
+	Message string `json:"message"`
+	Id      int    `json:"id"`
+	Href    string `json:"href"`
+}
+
+type apiScanStatus struct {
+	Status    string  `json:"status"`
+	IsRunning bool    `json:"is_running"`
+	IsPaused  bool    `json:"is_paused"`
+	Exception *string `json:"exception"`
+}
+
+func (s *apiScanStatus) Running() bool {
+	return s.IsRunning || s.IsPaused || s.Status == "Running"
+}
+
+type apiKbItemRef struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	Url  string `json:"url"`
+	Href string `json:"href"`
+}
+
+type apiKbList struct {
+	Items []*apiKbItemRef `json:"items"`
+}
+
+type apiReference struct {
+	Url   string `json:"url"`
+	Title string `json:"title"`
+}
+
+type apiKbItem struct {
+	Id              int             `json:"id"`
+	Name            string          `json:"name"`
+	Url             string          `json:"url"`
+	Var             string          `json:"var"`
+	Severity        string          `json:"severity"`
+	PluginName      string          `json:"plugin_name"`
+	Desc            string          `json:"desc"`
+	LongDescription string          `json:"long_description"`
+	FixGuidance     string          `json:"fix_guidance"`
+	References      []*apiReference `json:"references"`
+	TrafficHrefs    []string        `json:"traffic_hrefs"`
+}
+
+type apiTraffic struct {
+	Request  string `json:"request"`  // base64 encoded raw http request
+	Response string `json:"response"` // base64 encoded raw http response
+}
+
+type apiLogEntry struct {
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+	Type     string `json:"type"`
+	Time     string `json:"time"`
+}
+
+type apiLog struct {
+	Entries []*apiLogEntry `json:"entries"`
+	Next    *int           `json:"next"`
+}
+
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiClient talks to w3af REST API
+type apiClient struct {
+	url          string
+	username     string
+	password     string
+	pollInterval time.Duration
+	client       *http.Client
+}
+
+func newApiClient(conf *apiConf) (*apiClient, error) {
+	if conf == nil || conf.Url == "" {
+		return nil, fmt.Errorf("w3af api url is required")
+	}
+	c := &apiClient{
+		url:          strings.TrimRight(conf.Url, "/"),
+		username:     conf.Username,
+		password:     conf.Password,
+		pollInterval: defaultPollInterval,
+		client:       &http.Client{},
+	}
+	if conf.PollInterval > 0 {
+		c.pollInterval = time.Duration(conf.PollInterval) * time.Second
+	}
+	return c, nil
+}
+
+// Scan runs the scan with the profile and returns found vulnerabilities in the same format as xml report
+// and the scan log. The scan is stopped and removed when it's finished or the context is cancelled.
+func (c *apiClient) Scan(ctx context.Context, log *logrus.Entry, profile string, targets []string) (*XmlReport, string, error) {
+	created := &apiScanCreated{}
+	body := map[string]interface{}{
+		"scan_profile": profile,
+		"target_urls":  targets,
+	}
+	if err := c.do(ctx, "POST", "/scans/", body, created); err != nil {
+		return nil, "", err
+	}
+	finished := false
+	defer func() {
+		// scan is removed with a fresh context, because ctx could be already cancelled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		if err := c.remove(cleanupCtx, log, created.Id, !finished); err != nil {
+			log.WithFields(logrus.Fields{"scan": created.Id, "error": err.Error()}).Warn("w3af api scan wasn't removed")
+		}
+	}()
+
+	if err := c.wait(ctx, created.Id); err != nil {
+		return nil, "", err
+	}
+	finished = true
+	rep, err := c.kb(ctx, created.Id)
+	if err != nil {
+		return nil, "", err
+	}
+	return rep, c.log(ctx, created.Id), nil
+}
+
+// log returns all log messages of the scan, the log is optional so errors are ignored
+func (c *apiClient) log(ctx context.Context, id int) string {
+	lines := []string{}
+	for page := 0; ; page++ {
+		log := &apiLog{}
+		if err := c.do(ctx, "GET", fmt.Sprintf("/scans/%d/log?page=%d", id, page), nil, log); err != nil {
+			break
+		}
+		for _, entry := range log.Entries {
+			lines = append(lines, entry.Message)
+		}
+		if log.Next == nil || len(log.Entries) == 0 {
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wait polls scan status until the scan is finished
+func (c *apiClient) wait(ctx context.Context, id int) error {
+	return c.poll(ctx, id, true)
+}
+
+// poll requests scan status until the scan isn't running,
+// w3af exceptions are errors only if failOnException is set
+func (c *apiClient) poll(ctx context.Context, id int, failOnException bool) error {
+	for {
+		status := &apiScanStatus{}
+		if err := c.do(ctx, "GET", fmt.Sprintf("/scans/%d/status", id), nil, status); err != nil {
+			return err
+		}
+		if failOnException && status.Exception != nil && *status.Exception != "" {
+			return stackerr.Newf("w3af scan failed: %s", *status.Exception)
+		}
+		if !status.Running() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
+
+// remove deletes the scan, w3af_api refuses to delete running scans with 403,
+// so unfinished scans are stopped first and polled until they stop.
+func (c *apiClient) remove(ctx context.Context, log *logrus.Entry, id int, stop bool) error {
+	if stop {
+		// the scan could be already stopped by an exception, so the error isn't fatal
+		if err := c.do(ctx, "GET", fmt.Sprintf("/scans/%d/stop", id), nil, nil); err != nil {
+			log.WithFields(logrus.Fields{"scan": id, "error": err.Error()}).Warn("w3af api scan wasn't stopped")
+		}
+		if err := c.poll(ctx, id, false); err != nil {
+			return err
+		}
+	}
+	return c.do(ctx, "DELETE", fmt.Sprintf("/scans/%d", id), nil, nil)
+}
+
+// kb converts knowledge base items to vulnerabilities
+func (c *apiClient) kb(ctx context.Context, id int) (*XmlReport, error) {
+	list := &apiKbList{}
+	if err := c.do(ctx, "GET", fmt.Sprintf("/scans/%d/kb/", id), nil, list); err != nil {
+		return nil, err
+	}
+	rep := &XmlReport{}
+	for _, ref := range list.Items {
+		item := &apiKbItem{}
+		href := ref.Href
+		if href == "" {
+			href = fmt.Sprintf("/scans/%d/kb/%d", id, ref.Id)
+		}
+		if err := c.do(ctx, "GET", href, nil, item); err != nil {
+			return nil, err
+		}
+		vuln := &Vulnerability{
+			Id:              fmt.Sprintf("[%d]", item.Id),
+			Name:            item.Name,
+			Plugin:          item.PluginName,
+			Severity:        item.Severity,
+			Url:             item.Url,
+			Var:             item.Var,
+			Description:     item.Desc,
+			LongDescription: item.LongDescription,
+			FixGuidance:     item.FixGuidance,
+		}
+		for _, r := range item.References {
+			vuln.References = append(vuln.References, &Reference{Url: r.Url, Title: r.Title})
+		}
+		for _, trafficHref := range item.TrafficHrefs {
+			trans, err := c.traffic(ctx, trafficHref)
+			if err != nil {
+				return nil, err
+			}
+			vuln.HttpTransactions = append(vuln.HttpTransactions, trans)
+		}
+		if len(vuln.HttpTransactions) > 0 && vuln.HttpTransactions[0].Request != nil {
+			if method, _, _, ok := parseRequestLine(vuln.HttpTransactions[0].Request.Status); ok {
+				vuln.Method = method
+			}
+		}
+		rep.Vulnerabilities = append(rep.Vulnerabilities, vuln)
+	}
+	return rep, nil
+}
+
+func (c *apiClient) traffic(ctx context.Context, href string) (*HttpTransaction, error) {
+	traffic := &apiTraffic{}
+	if err := c.do(ctx, "GET", href, nil, traffic); err != nil {
+		return nil, err
+	}
+	trans := &HttpTransaction{}
+	// traffic id is the last part of href: /scans/0/traffic/45
+	fmt.Sscanf(href[strings.LastIndex(href, "/")+1:], "%d", &trans.Id)
+	var err error
+	if trans.Request, err = parseRawEntity(traffic.Request); err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	if trans.Response, err = parseRawEntity(traffic.Response); err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	return trans, nil
+}
+
+// parseRawEntity parses base64 encoded raw http message
+func parseRawEntity(encoded string) (*HttpEntity, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(bytes.NewReader(raw))
+	ent := &HttpEntity{}
+	for first := true; ; first = false {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if first {
+			ent.Status = line
+		} else if line == "" {
+			break
+		} else if i := strings.Index(line, ":"); i > 0 {
+			ent.Headers = append(ent.Headers, &HttpHeader{
+				Field:   line[:i],
+				Content: strings.TrimSpace(line[i+1:]),
+			})
+		}
+		if err == io.EOF {
+			return ent, nil
+		}
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > 0 {
+		ent.Body = &HttpBody{
+			ContentEncoding: encodingBase64,
+			Content:         []byte(base64.StdEncoding.EncodeToString(body)),
+		}
+	}
+	return ent, nil
+}
+
+func (c *apiClient) do(ctx context.Context, method, path string, send, recv interface{}) error {
+	var body io.Reader
+	if send != nil {
+		data, err := json.Marshal(send)
+		if err != nil {
+			return stackerr.Wrap(err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	req.Cancel = ctx.Done()
+	if send != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return stackerr.Wrap(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	if resp.StatusCode >= 400 {
+		apiErr := &apiError{}
+		json.Unmarshal(data, apiErr)
+		return stackerr.Newf("w3af api %s %s returned %d: %s", method, path, resp.StatusCode, apiErr.Message)
+	}
+	if recv == nil {
+		return nil
+	}
+	return stackerr.Wrap(json.Unmarshal(data, recv))
+}
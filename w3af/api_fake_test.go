@@ -0,0 +1,166 @@
+package w3af
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// fakeApi is an in-process stand-in for w3af REST API (w3af_api)
+type fakeApi struct {
+	*httptest.Server
+
+	mu sync.Mutex
+
+	// scan settings
+	RunningPolls int // scan is running for this number of status requests
+	Exception    string
+	Items        []*apiKbItem
+	Traffic      map[int]*apiTraffic
+	Logs         []*apiLogEntry
+
+	// recorded requests
+	Profile     string
+	Targets     []string
+	StatusPolls int
+	Stopped     bool
+	Deleted     bool
+	Auth        string
+}
+
+// running is true till the status is polled RunningPolls times
+func (f *fakeApi) running() bool {
+	return f.StatusPolls <= f.RunningPolls
+}
+
+func newFakeApi() *fakeApi {
+	f := &fakeApi{
+		Traffic: map[int]*apiTraffic{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// AddVuln adds kb item with one transaction built from raw http messages
+func (f *fakeApi) AddVuln(item *apiKbItem, rawRequest, rawResponse string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	trafficId := len(f.Traffic) + 1
+	f.Traffic[trafficId] = &apiTraffic{
+		Request:  base64.StdEncoding.EncodeToString([]byte(rawRequest)),
+		Response: base64.StdEncoding.EncodeToString([]byte(rawResponse)),
+	}
+	item.Id = len(f.Items)
+	item.TrafficHrefs = append(item.TrafficHrefs, fmt.Sprintf("/scans/0/traffic/%d", trafficId))
+	f.Items = append(f.Items, item)
+}
+
+func (f *fakeApi) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if user, pass, ok := r.BasicAuth(); ok {
+		f.Auth = user + ":" + pass
+	}
+	var id int
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/scans/":
+		body := struct {
+			Profile string   `json:"scan_profile"`
+			Targets []string `json:"target_urls"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		f.Profile, f.Targets = body.Profile, body.Targets
+		w.WriteHeader(http.StatusCreated)
+		f.json(w, &apiScanCreated{Message: "Success", Id: 0, Href: "/scans/0"})
+	case r.Method == "GET" && r.URL.Path == "/scans/0/status":
+		f.StatusPolls++
+		status := &apiScanStatus{Status: "Stopped"}
+		if f.running() {
+			status.Status, status.IsRunning = "Running", true
+		}
+		if f.Exception != "" {
+			status.Exception = &f.Exception
+		}
+		f.json(w, status)
+	case r.Method == "GET" && r.URL.Path == "/scans/0/kb/":
+		list := &apiKbList{Items: []*apiKbItemRef{}}
+		for _, item := range f.Items {
+			list.Items = append(list.Items, &apiKbItemRef{
+				Id:   item.Id,
+				Name: item.Name,
+				Url:  item.Url,
+				Href: fmt.Sprintf("/scans/0/kb/%d", item.Id),
+			})
+		}
+		f.json(w, list)
+	case r.Method == "GET" && r.URL.Path == "/scans/0/log":
+		// two entries per page
+		page := 0
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		log := &apiLog{Entries: []*apiLogEntry{}}
+		for i := page * 2; i < len(f.Logs) && i < page*2+2; i++ {
+			log.Entries = append(log.Entries, f.Logs[i])
+		}
+		if (page+1)*2 < len(f.Logs) {
+			next := page + 1
+			log.Next = &next
+		}
+		f.json(w, log)
+	case r.Method == "GET" && scanf(r.URL.Path, "/scans/0/kb/%d", &id):
+		if id < 0 || id >= len(f.Items) {
+			f.error(w, http.StatusNotFound, "Not found")
+			return
+		}
+		f.json(w, f.Items[id])
+	case r.Method == "GET" && scanf(r.URL.Path, "/scans/0/traffic/%d", &id):
+		traffic, ok := f.Traffic[id]
+		if !ok {
+			f.error(w, http.StatusNotFound, "Not found")
+			return
+		}
+		f.json(w, traffic)
+	case r.Method == "GET" && r.URL.Path == "/scans/0/stop":
+		if !f.running() {
+			f.error(w, http.StatusForbidden, "Scan can not be stop")
+			return
+		}
+		// the scan stops asynchronously, so the next status poll still shows it running
+		f.Stopped = true
+		f.RunningPolls = f.StatusPolls + 1
+		f.json(w, map[string]string{"message": "Stopping scan"})
+	case r.Method == "DELETE" && r.URL.Path == "/scans/0":
+		if f.running() {
+			f.error(w, http.StatusForbidden, "Scan is not ready to be cleared")
+			return
+		}
+		f.Deleted = true
+		f.json(w, map[string]string{"message": "Success"})
+	default:
+		f.error(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (f *fakeApi) json(w http.ResponseWriter, data interface{}) {
+	json.NewEncoder(w).Encode(data)
+}
+
+func (f *fakeApi) error(w http.ResponseWriter, code int, message string) {
+	w.WriteHeader(code)
+	f.json(w, &apiError{Code: code, Message: message})
+}
+
+func scanf(path, format string, id *int) bool {
+	prefix := format[:strings.Index(format, "%")]
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	n, err := fmt.Sscanf(path, format, id)
+	return err == nil && n == 1
+}
@@ -0,0 +1,226 @@
+package w3af
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+const (
+	testRawRequest = "GET /xss?in=%3Cscript%3E HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"User-Agent: w3af.org\r\n\r\n"
+	testRawResponse = "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n\r\n" +
+		"<html><script></html>"
+)
+
+var testLog = logrus.WithField("test", true)
+
+func newTestApi() *fakeApi {
+	api := newFakeApi()
+	api.RunningPolls = 2
+	api.AddVuln(&apiKbItem{
+		Name:        "Cross site scripting vulnerability",
+		Url:         "http://example.com/xss",
+		Var:         "in",
+		Severity:    "Medium",
+		PluginName:  "xss",
+		Desc:        "XSS was found",
+		FixGuidance: "Escape output",
+		References:  []*apiReference{&apiReference{Url: "http://owasp.org", Title: "OWASP"}},
+	}, testRawRequest, testRawResponse)
+	return api
+}
+
+func TestParseRawEntity(t *testing.T) {
+	ent, err := parseRawEntity(base64.StdEncoding.EncodeToString([]byte(testRawResponse)))
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 OK", ent.Status)
+	require.Len(t, ent.Headers, 1)
+	assert.Equal(t, "Content-Type", ent.Headers[0].Field)
+	assert.Equal(t, "text/html; charset=utf-8", ent.Headers[0].Content)
+	require.NotNil(t, ent.Body)
+	assert.Equal(t, "base64", ent.Body.ContentEncoding)
+
+	ent, err = parseRawEntity(base64.StdEncoding.EncodeToString([]byte("HTTP/1.1 204 No Content\r\n")))
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 204 No Content", ent.Status)
+	assert.Nil(t, ent.Body)
+
+	ent, err = parseRawEntity("")
+	require.NoError(t, err)
+	assert.Nil(t, ent)
+
+	_, err = parseRawEntity("!!!")
+	assert.Error(t, err)
+}
+
+func TestApiClientScan(t *testing.T) {
+	api := newTestApi()
+	defer api.Close()
+
+	client, err := newApiClient(&apiConf{Url: api.URL + "/", Username: "admin", Password: "secret"})
+	require.NoError(t, err)
+	client.pollInterval = time.Millisecond
+
+	api.Logs = []*apiLogEntry{
+		&apiLogEntry{Message: "one"}, &apiLogEntry{Message: "two"}, &apiLogEntry{Message: "three"},
+	}
+	xmlReport, log, err := client.Scan(context.Background(), testLog, "[target]\ntarget = http://example.com", []string{"http://example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\nthree", log)
+	assert.Equal(t, 3, api.StatusPolls)
+	assert.False(t, api.Stopped)
+	assert.True(t, api.Deleted)
+	assert.Equal(t, "admin:secret", api.Auth)
+	assert.Equal(t, []string{"http://example.com"}, api.Targets)
+
+	require.Len(t, xmlReport.Vulnerabilities, 1)
+	vuln := xmlReport.Vulnerabilities[0]
+	assert.Equal(t, "xss", vuln.Plugin)
+	assert.Equal(t, "GET", vuln.Method)
+	assert.Equal(t, "in", vuln.Var)
+	require.Len(t, vuln.HttpTransactions, 1)
+	assert.Equal(t, 1, vuln.HttpTransactions[0].Id)
+
+	// the same issue mapping is used
+	issues, err := transformXmlReport(xmlReport, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, issue.SeverityMedium, issues[0].Severity)
+	assert.Equal(t, []*issue.Reference{&issue.Reference{Url: "http://owasp.org", Title: "OWASP"}}, issues[0].References)
+	trans := issues[0].Vector.HttpTransactions[0]
+	assert.Equal(t, "http://example.com/xss?in=%3Cscript%3E", trans.Url)
+	assert.Equal(t, []string{"in"}, trans.Params)
+	require.Len(t, issues[0].Extras, 1)
+	assert.Equal(t, "Injected parameter: in", issues[0].Extras[0].Title)
+	assert.Equal(t, "<html><script></html>", trans.Response.Body.Content)
+	assert.Equal(t, "text", trans.Response.Body.ContentEncoding)
+}
+
+func TestApiClientErrors(t *testing.T) {
+	_, err := newApiClient(nil)
+	assert.Error(t, err)
+	_, err = newApiClient(&apiConf{})
+	assert.Error(t, err)
+
+	// scan exception
+	api := newTestApi()
+	defer api.Close()
+	api.Exception = "target is unreachable"
+	client, err := newApiClient(&apiConf{Url: api.URL})
+	require.NoError(t, err)
+	client.pollInterval = time.Millisecond
+	_, _, err = client.Scan(context.Background(), testLog, "", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "target is unreachable")
+	assert.True(t, api.Deleted)
+
+	// context is cancelled while scan is running
+	api.Exception = ""
+	api.Deleted = false
+	api.RunningPolls = 1000
+	client.pollInterval = 5 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, err = client.Scan(ctx, testLog, "", nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	// the running scan is stopped before it's deleted
+	assert.True(t, api.Stopped)
+	assert.True(t, api.Deleted)
+
+	// broken traffic
+	api.RunningPolls = 0
+	api.Traffic[1].Request = "!!!"
+	_, _, err = client.Scan(context.Background(), testLog, "", nil)
+	assert.Error(t, err)
+
+	// api error
+	client.url += "/unknown"
+	_, _, err = client.Scan(context.Background(), testLog, "", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "returned 404: Not found")
+}
+
+func TestApiClientRemove(t *testing.T) {
+	api := newTestApi()
+	defer api.Close()
+	api.RunningPolls = 1000
+	client, err := newApiClient(&apiConf{Url: api.URL})
+	require.NoError(t, err)
+	client.pollInterval = time.Millisecond
+	ctx := context.Background()
+
+	// running scan can't be deleted
+	err = client.do(ctx, "DELETE", "/scans/0", nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "returned 403")
+	assert.False(t, api.Deleted)
+
+	require.NoError(t, client.remove(ctx, testLog, 0, true))
+	assert.True(t, api.Stopped)
+	assert.True(t, api.Deleted)
+
+	// stop of the stopped scan fails, but it's still deleted
+	api.Stopped, api.Deleted = false, false
+	require.NoError(t, client.remove(ctx, testLog, 0, true))
+	assert.False(t, api.Stopped)
+	assert.True(t, api.Deleted)
+}
+
+func TestW3afHandleApi(t *testing.T) {
+	api := newTestApi()
+	defer api.Close()
+	api.RunningPolls = 0
+
+	formData, err := json.Marshal(map[string]interface{}{
+		"type":    "plan",
+		"data":    "[audit]\nxss",
+		"backend": BackendApi,
+		"api":     map[string]interface{}{"url": api.URL},
+	})
+	require.NoError(t, err)
+	conf := &plan.Conf{
+		Target:   "http://example.com",
+		FormData: string(formData),
+	}
+
+	bg := context.Background()
+	client := &ClientMock{}
+	client.On("SendReport", mock.Anything, mock.Anything).Return(nil).Once()
+
+	err = newTestW3af(t).Handle(bg, client, conf)
+	require.NoError(t, err)
+	client.Mock.AssertExpectations(t)
+
+	assert.Contains(t, api.Profile, "[audit]\nxss")
+	assert.Contains(t, api.Profile, "target = http://example.com")
+	require.Len(t, client.Reports, 1)
+	sent := client.Reports[0]
+	assert.Equal(t, report.TypeMulti, sent.Type)
+	issues := sent.GetAllIssues()
+	require.Len(t, issues, 1)
+	assert.Equal(t, "[high confidence] Cross site scripting vulnerability", issues[0].Summary)
+	assert.NotEmpty(t, issues[0].UniqId)
+	assert.Equal(t, http.Header{
+		"Host":       []string{"example.com"},
+		"User-Agent": []string{"w3af.org"},
+	}, issues[0].Vector.HttpTransactions[0].Request.Header)
+
+	// unknown backend
+	conf.FormData = `{"backend": "ssh"}`
+	err = newTestW3af(t).Handle(bg, client, conf)
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package w3af
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reservedArgs are set by the script or break its contract
+var reservedArgs = map[string]bool{
+	"-P": true, "--profile-run": true,
+	"-p": true, "--profile": true,
+	"-s": true, "--script": true,
+	"-h": true, "--help": true,
+}
+
+// allowedArgs are w3af console flags which can be passed in form data
+var allowedArgs = map[string]bool{
+	"-n": true, "--no-update": true,
+	"-f": true, "--force-update": true,
+	"-y": true,
+}
+
+// argName returns flag name without value
+func argName(arg string) string {
+	return strings.SplitN(arg, "=", 2)[0]
+}
+
+// validateArgs checks extra w3af args from form data
+func validateArgs(args []string) error {
+	noUpdate, forceUpdate := false, false
+	for _, arg := range args {
+		if name := argName(arg); reservedArgs[name] {
+			return fmt.Errorf("w3af arg %s is set by the script and can't be overridden", name)
+		}
+		if !allowedArgs[arg] {
+			allowed := []string{}
+			for name := range allowedArgs {
+				allowed = append(allowed, name)
+			}
+			sort.Strings(allowed)
+			return fmt.Errorf("w3af arg %q isn't allowed, use %s", arg, strings.Join(allowed, ", "))
+		}
+		switch arg {
+		case "-n", "--no-update":
+			noUpdate = true
+		case "-f", "--force-update":
+			forceUpdate = true
+		}
+	}
+	if noUpdate && forceUpdate {
+		return fmt.Errorf("w3af args --no-update and --force-update can't be used together")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package w3af
+
+import (
+	"testing"
+
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestValidateArgs(t *testing.T) {
+	require.NoError(t, validateArgs(nil))
+	require.NoError(t, validateArgs([]string{"-n", "-y"}))
+	require.NoError(t, validateArgs([]string{"--force-update"}))
+
+	for msg, args := range map[string][]string{
+		"is set by the script":      []string{"-P", "/tmp/profile.pw3af"},
+		"--profile-run is set":      []string{"--profile-run=/tmp/profile.pw3af"},
+		"-s is set":                 []string{"-n", "-s"},
+		`"-v" isn't allowed, use`:   []string{"-v"},
+		`"-n -y" isn't allowed`:     []string{"-n -y"},
+		`"-y; rm -rf /" isn't`:      []string{"-y; rm -rf /"},
+		"can't be used together":    []string{"--no-update", "-f"},
+		`"--no-update=1" isn't all`: []string{"--no-update=1"},
+	} {
+		err := validateArgs(args)
+		require.Error(t, err, "args %v", args)
+		assert.Contains(t, err.Error(), msg)
+	}
+}
+
+func TestW3afHandleArgs(t *testing.T) {
+	conf := &plan.Conf{
+		Target:   "http://192.168.1.35:8082/",
+		FormData: `{"type": "plan", "data": "[audit]\nxss", "args": ["-n", "-y"]}`,
+	}
+	bg := context.Background()
+	client := &flakyClient{}
+	require.NoError(t, newTestW3af(t, WithExtraArgs("--no-color")).Handle(bg, client, conf))
+	require.Len(t, client.Steps, 1)
+	assert.Equal(t, "-P /share/profile.pw3af --no-color -n -y", client.Steps[0].Conf.CommandArgs)
+
+	// without profile
+	conf.FormData = `{"args": ["--no-update"]}`
+	client = &flakyClient{}
+	require.NoError(t, newTestW3af(t).Handle(bg, client, conf))
+	assert.Equal(t, "--no-update", client.Steps[0].Conf.CommandArgs)
+
+	// profile can't be overridden
+	conf.FormData = `{"type": "plan", "data": "[audit]\nxss", "args": ["-P", "/tmp/other.pw3af"]}`
+	client = &flakyClient{}
+	err := newTestW3af(t).Handle(bg, client, conf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "w3af arg -P is set by the script")
+	assert.Len(t, client.Steps, 0)
+	assert.Len(t, client.Reports, 0)
+
+	// api backend doesn't run console
+	conf.FormData = `{"backend": "api", "args": ["-n"]}`
+	err = newTestW3af(t).Handle(bg, client, conf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only by util backend")
+}
@@ -0,0 +1,160 @@
+package w3af
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/bearded-web/bearded/pkg/script"
+	"github.com/facebookgo/stackerr"
+	"golang.org/x/net/context"
+)
+
+// baseline modes
+const (
+	BaselineInfo = "info"
+	BaselineOmit = "omit"
+)
+
+// BaselineFinding is an accepted finding
+type BaselineFinding struct {
+	Fingerprint   string `json:"fingerprint"`
+	Summary       string `json:"summary,omitempty"`
+	Url           string `json:"url,omitempty"`
+	Justification string `json:"justification"`
+}
+
+// Baseline is a list of accepted findings
+type Baseline struct {
+	Findings []*BaselineFinding `json:"findings"`
+}
+
+type baselineConf struct {
+	File     string             `json:"file"`     // file id with baseline
+	Findings []*BaselineFinding `json:"findings"` // or accepted findings
+	Mode     string             `json:"mode"`     // info or omit, info by default
+}
+
+// ParseBaseline parses baseline json
+func ParseBaseline(data []byte) (*Baseline, error) {
+	baseline := &Baseline{}
+	if err := json.Unmarshal(data, baseline); err != nil {
+		return nil, err
+	}
+	for i, f := range baseline.Findings {
+		if f == nil || f.Fingerprint == "" {
+			return nil, fmt.Errorf("baseline finding %d doesn't have a fingerprint", i+1)
+		}
+	}
+	return baseline, nil
+}
+
+// GenerateBaseline makes baseline with all vulnerabilities from w3af xml report
+func GenerateBaseline(xmlData []byte, justification string) (*Baseline, error) {
+	xmlReport, err := parseXml(xmlData)
+	if err != nil {
+		return nil, err
+	}
+	baseline := &Baseline{Findings: []*BaselineFinding{}}
+	seen := map[string]bool{}
+	for _, vuln := range xmlReport.Vulnerabilities {
+		fingerprint := vulnFingerprint(vuln)
+		if seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+		baseline.Findings = append(baseline.Findings, &BaselineFinding{
+			Fingerprint:   fingerprint,
+			Summary:       vuln.Name,
+			Url:           vuln.Url,
+			Justification: justification,
+		})
+	}
+	return baseline, nil
+}
+
+// CheckBaseline returns issues from w3af xml report which aren't in the baseline
+func CheckBaseline(xmlData []byte, baseline *Baseline) ([]*issue.Issue, error) {
+	xmlReport, err := parseXml(xmlData)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := newBaselineFilter(baseline, BaselineOmit)
+	if err != nil {
+		return nil, err
+	}
+	// it isn't a scan, so the findings aren't counted in metrics
+	filter.metrics = noopMetrics{}
+	xmlReport.Errors = nil
+	return transformXmlReport(xmlReport, nil, 0, processorFunc(setFingerprint), filter)
+}
+
+// baselineFilter downgrades or omits accepted findings
+type baselineFilter struct {
+	mode     string
+	findings map[string]*BaselineFinding
+	metrics  findingMetrics
+
+	// number of matched findings
+	Matched int
+}
+
+func newBaselineFilter(baseline *Baseline, mode string) (*baselineFilter, error) {
+	switch mode {
+	case "":
+		mode = BaselineInfo
+	case BaselineInfo, BaselineOmit:
+	default:
+		return nil, fmt.Errorf("baseline mode should be info or omit, but got %s", mode)
+	}
+	f := &baselineFilter{
+		mode:     mode,
+		findings: map[string]*BaselineFinding{},
+		metrics:  scanMetrics{},
+	}
+	if baseline != nil {
+		for _, finding := range baseline.Findings {
+			if finding == nil {
+				continue
+			}
+			f.findings[finding.Fingerprint] = finding
+		}
+	}
+	return f, nil
+}
+
+// getBaselineFilter makes baseline filter with findings from form data and the baseline file
+func getBaselineFilter(ctx context.Context, client script.ClientV1, conf *baselineConf) (*baselineFilter, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	baseline := &Baseline{Findings: conf.Findings}
+	if conf.File != "" {
+		data, err := client.DownloadFile(ctx, conf.File)
+		if err != nil {
+			return nil, stackerr.Wrap(err)
+		}
+		fileBaseline, err := ParseBaseline(data)
+		if err != nil {
+			return nil, stackerr.Wrap(err)
+		}
+		baseline.Findings = append(baseline.Findings, fileBaseline.Findings...)
+	}
+	return newBaselineFilter(baseline, conf.Mode)
+}
+
+// Process implements findingProcessor
+func (f *baselineFilter) Process(vuln *Vulnerability, issueObj *issue.Issue) bool {
+	finding, ok := f.findings[vulnFingerprint(vuln)]
+	if !ok {
+		return true
+	}
+	f.Matched++
+	if f.mode == BaselineOmit {
+		f.metrics.Dropped(dropBaseline)
+		return false
+	}
+	issueObj.Desc += fmt.Sprintf("\n\n###Accepted in baseline with %s severity:\n %s", issueObj.Severity, finding.Justification)
+	issueObj.Severity = issue.SeverityInfo
+	return true
+}
@@ -0,0 +1,86 @@
+package w3af
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestBaseline(t *testing.T) {
+	xmlData := loadTestData("report.xml")
+	baseline, err := GenerateBaseline(xmlData, "legacy")
+	require.NoError(t, err)
+	require.True(t, len(baseline.Findings) > 0)
+	assert.Equal(t, "legacy", baseline.Findings[0].Justification)
+	assert.Equal(t, "Cross site scripting vulnerability", baseline.Findings[0].Summary)
+
+	// serialization
+	raw, err := json.Marshal(baseline)
+	require.NoError(t, err)
+	parsed, err := ParseBaseline(raw)
+	require.NoError(t, err)
+	assert.Equal(t, baseline, parsed)
+
+	_, err = ParseBaseline([]byte(`{"findings": [{"justification": "no fingerprint"}]}`))
+	assert.Error(t, err)
+	_, err = ParseBaseline([]byte(`[]`))
+	assert.Error(t, err)
+
+	// everything is accepted
+	issues, err := CheckBaseline(xmlData, baseline)
+	require.NoError(t, err)
+	assert.Len(t, issues, 0)
+
+	// the first finding is new
+	accepted := &Baseline{Findings: baseline.Findings[1:]}
+	issues, err = CheckBaseline(xmlData, accepted)
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, baseline.Findings[0].Fingerprint, issues[0].UniqId)
+
+	// info mode
+	filter, err := newBaselineFilter(accepted, "")
+	require.NoError(t, err)
+	xmlReport, err := parseXml(xmlData)
+	require.NoError(t, err)
+	issues, err = transformXmlReport(xmlReport, nil, 0, filter)
+	require.NoError(t, err)
+	require.Len(t, issues, 23)
+	assert.Equal(t, issue.SeverityMedium, issues[2].Severity)
+	assert.Equal(t, issue.SeverityInfo, issues[3].Severity)
+	assert.Contains(t, issues[3].Desc, "###Accepted in baseline with medium severity:\n legacy")
+	assert.Equal(t, 20, filter.Matched)
+
+	_, err = newBaselineFilter(baseline, "drop")
+	assert.Error(t, err)
+}
+
+func TestGetBaselineFilter(t *testing.T) {
+	bg := context.Background()
+	client := &ClientMock{}
+	client.On("DownloadFile", bg, "baseline").Return([]byte(`{"findings": [{"fingerprint": "2", "justification": "file"}]}`), nil).Once()
+	client.On("DownloadFile", bg, "missing").Return([]byte(nil), fmt.Errorf("not found")).Once()
+
+	filter, err := getBaselineFilter(bg, client, nil)
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	filter, err = getBaselineFilter(bg, client, &baselineConf{
+		File:     "baseline",
+		Findings: []*BaselineFinding{&BaselineFinding{Fingerprint: "1", Justification: "form"}},
+		Mode:     BaselineOmit,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, BaselineOmit, filter.mode)
+	assert.Len(t, filter.findings, 2)
+
+	_, err = getBaselineFilter(bg, client, &baselineConf{File: "missing"})
+	assert.Error(t, err)
+
+	client.Mock.AssertExpectations(t)
+}
@@ -0,0 +1,250 @@
+package w3af
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const (
+	encodingText   = "text"
+	encodingBase64 = "base64"
+)
+
+// decompressed bodies are limited to this size if response bodies aren't limited by redact config
+const defaultMaxDecompressed = 10 << 20
+
+var (
+	// single byte charsets by normalized names like windows1251 or iso88591
+	charmaps = map[string]encoding.Encoding{}
+	// common labels which differ from the names
+	charsetAliases = map[string]string{
+		"ascii":        "windows1252", // browsers treat ascii and latin1 as windows-1252
+		"usascii":      "windows1252",
+		"latin1":       "windows1252",
+		"l1":           "windows1252",
+		"latin2":       "iso88592",
+		"cyrillic":     "iso88595",
+		"koi":          "koi8r",
+		"koi8":         "koi8r",
+		"mac":          "macintosh",
+		"xmacroman":    "macintosh",
+		"xmaccyrillic": "macintoshcyrillic",
+		"tis620":       "windows874",
+	}
+	codePageLabel = regexp.MustCompile(`^(?:x?cp|csibm|ibm|windows)(\d+)$`)
+)
+
+func init() {
+	for _, enc := range charmap.All {
+		if name, ok := enc.(fmt.Stringer); ok {
+			charmaps[normalizeCharset(name.String())] = enc
+		}
+	}
+}
+
+// normalizeCharset keeps only letters and digits of the charset label
+func normalizeCharset(label string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return -1
+	}, label)
+}
+
+// charsetEncoding returns decoder of single byte or utf-16 charset or nil for utf-8 and unknown charsets,
+// multibyte cjk charsets aren't supported, such bodies are kept as base64 if they aren't valid utf-8
+func charsetEncoding(label string) encoding.Encoding {
+	name := normalizeCharset(label)
+	switch name {
+	case "utf16", "utf16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case "utf16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	}
+	if alias, ok := charsetAliases[name]; ok {
+		name = alias
+	}
+	if match := codePageLabel.FindStringSubmatch(name); match != nil {
+		for _, prefix := range []string{"windows", "ibmcodepage", "windowscodepage"} {
+			if enc, ok := charmaps[prefix+match[1]]; ok {
+				return enc
+			}
+		}
+	}
+	return charmaps[name]
+}
+
+// decodeBody converts w3af body to utf-8 text,
+// binary content is kept as base64. Compressed body is kept as is if it's bigger than maxBody when decompressed,
+// defaultMaxDecompressed is used if maxBody isn't set.
+func decodeBody(body *HttpBody, header http.Header, maxBody int) *issue.HttpBody {
+	var data []byte
+	switch body.ContentEncoding {
+	case encodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(stripSpaces(string(body.Content)))
+		if err != nil {
+			// keep as is, it's better than nothing
+			return &issue.HttpBody{
+				ContentEncoding: encodingBase64,
+				Content:         string(body.Content),
+			}
+		}
+		data = decoded
+	case encodingText:
+		data = body.Content
+	default:
+		return &issue.HttpBody{
+			ContentEncoding: encodingText,
+			Content:         fmt.Sprintf("%s content encoding isn't supported yet", body.ContentEncoding),
+		}
+	}
+
+	if maxBody <= 0 {
+		maxBody = defaultMaxDecompressed
+	}
+	data = decompress(data, header.Get("Content-Encoding"), maxBody)
+	if text, ok := toUtf8(data, header.Get("Content-Type")); ok {
+		return &issue.HttpBody{
+			ContentEncoding: encodingText,
+			Content:         text,
+		}
+	}
+	return &issue.HttpBody{
+		ContentEncoding: encodingBase64,
+		Content:         base64.StdEncoding.EncodeToString(data),
+	}
+}
+
+// decompress returns decompressed data or data itself if it isn't compressed or is bigger than limit
+// when decompressed, w3af usually saves bodies already decompressed but keeps the header
+func decompress(data []byte, contentEncoding string, limit int) []byte {
+	var (
+		out []byte
+		err error
+	)
+	// a hostile response could be a decompression bomb
+	readAll := func(r io.Reader) ([]byte, error) {
+		return ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	}
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "gzip", "x-gzip":
+		// check gzip magic number
+		if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+			return data
+		}
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			out, err = readAll(r)
+		}
+	case "deflate":
+		// deflate could be zlib wrapped or raw
+		if isZlib(data) {
+			var r io.Reader
+			if r, err = zlib.NewReader(bytes.NewReader(data)); err == nil {
+				out, err = readAll(r)
+			}
+		} else {
+			out, err = readAll(flate.NewReader(bytes.NewReader(data)))
+		}
+	default:
+		return data
+	}
+	if err != nil || len(out) > limit {
+		return data
+	}
+	return out
+}
+
+// isZlib checks zlib header: deflate compression method and header checksum, see rfc 1950
+func isZlib(data []byte) bool {
+	return len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
+
+// toUtf8 transcodes data from charset in content type to utf-8,
+// returns false if the data is binary
+func toUtf8(data []byte, contentType string) (string, bool) {
+	charset := ""
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		charset = strings.ToLower(params["charset"])
+	}
+	var text string
+	enc := charsetEncoding(charset)
+	switch {
+	case enc == nil:
+		// utf-8, ascii and unknown charsets
+		if !utf8.Valid(data) {
+			return "", false
+		}
+		text = string(data)
+	case strings.HasPrefix(normalizeCharset(charset), "utf16"):
+		if len(data)%2 != 0 {
+			return "", false
+		}
+		// byte order mark wins over the declared endianness
+		decoded, _, err := transform.Bytes(unicode.BOMOverride(enc.NewDecoder()), data)
+		if err != nil {
+			return "", false
+		}
+		text = string(decoded)
+	default:
+		decoded, err := enc.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", false
+		}
+		text = string(decoded)
+	}
+	if isBinary(text) {
+		return "", false
+	}
+	return text, true
+}
+
+// isBinary checks if text has null bytes or too many control characters
+func isBinary(text string) bool {
+	if len(text) == 0 {
+		return false
+	}
+	control := 0
+	total := 0
+	for _, r := range text {
+		total++
+		if r == 0 {
+			return true
+		}
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' {
+			control++
+		}
+	}
+	return control*10 > total
+}
+
+func stripSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\n', '\r':
+			return -1
+		}
+		return r
+	}, s)
+}
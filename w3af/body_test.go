@@ -0,0 +1,146 @@
+package w3af
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeBody(t *testing.T) {
+	header := http.Header{}
+
+	// text
+	body := decodeBody(&HttpBody{ContentEncoding: "text", Content: []byte("hello")}, header, 0)
+	assert.Equal(t, "text", body.ContentEncoding)
+	assert.Equal(t, "hello", body.Content)
+
+	// base64 with line breaks
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte("aGVs\n  bG8=\n")}, header, 0)
+	assert.Equal(t, "text", body.ContentEncoding)
+	assert.Equal(t, "hello", body.Content)
+
+	// broken base64 is kept as is
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte("aGVs!")}, header, 0)
+	assert.Equal(t, "base64", body.ContentEncoding)
+	assert.Equal(t, "aGVs!", body.Content)
+
+	// binary
+	png := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00}
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString(png))}, header, 0)
+	assert.Equal(t, "base64", body.ContentEncoding)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(png), body.Content)
+
+	// unknown encoding
+	body = decodeBody(&HttpBody{ContentEncoding: "xxx", Content: []byte("hello")}, header, 0)
+	assert.Equal(t, "text", body.ContentEncoding)
+	assert.Contains(t, body.Content, "isn't supported")
+
+	// gzip
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte("hello gzip"))
+	gz.Close()
+	header.Set("Content-Encoding", "gzip")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))}, header, 0)
+	assert.Equal(t, "text", body.ContentEncoding)
+	assert.Equal(t, "hello gzip", body.Content)
+
+	// already decompressed by w3af
+	body = decodeBody(&HttpBody{ContentEncoding: "text", Content: []byte("hello")}, header, 0)
+	assert.Equal(t, "hello", body.Content)
+
+	// deflate
+	buf.Reset()
+	zw := zlib.NewWriter(buf)
+	zw.Write([]byte("hello deflate"))
+	zw.Close()
+	header.Set("Content-Encoding", "deflate")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))}, header, 0)
+	assert.Equal(t, "text", body.ContentEncoding)
+	assert.Equal(t, "hello deflate", body.Content)
+
+	// raw deflate
+	buf.Reset()
+	fw, _ := flate.NewWriter(buf, flate.DefaultCompression)
+	fw.Write([]byte("hello raw deflate"))
+	fw.Close()
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))}, header, 0)
+	assert.Equal(t, "hello raw deflate", body.Content)
+
+	// broken zlib stream isn't decoded as raw deflate
+	zlibData := []byte{0x78, 0x9c, 0xff, 0xff, 0xff}
+	assert.Equal(t, zlibData, decompress(zlibData, "deflate", 1024))
+
+	// already decompressed by w3af
+	body = decodeBody(&HttpBody{ContentEncoding: "text", Content: []byte("hello deflate")}, header, 0)
+	assert.Equal(t, "hello deflate", body.Content)
+
+	// decompressed body is bigger than limit, compressed one is kept
+	buf.Reset()
+	gz = gzip.NewWriter(buf)
+	gz.Write(bytes.Repeat([]byte("a"), 4096))
+	gz.Close()
+	header.Set("Content-Encoding", "gzip")
+	bomb := base64.StdEncoding.EncodeToString(buf.Bytes())
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(bomb)}, header, 1024)
+	assert.Equal(t, "base64", body.ContentEncoding)
+	assert.Equal(t, bomb, body.Content)
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(bomb)}, header, 4096)
+	assert.Equal(t, "text", body.ContentEncoding)
+	assert.Len(t, body.Content, 4096)
+	header.Del("Content-Encoding")
+
+	// charsets
+	header.Set("Content-Type", "text/html; charset=ISO-8859-1")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString([]byte("caf\xe9")))}, header, 0)
+	assert.Equal(t, "text", body.ContentEncoding)
+	assert.Equal(t, "café", body.Content)
+
+	header.Set("Content-Type", "text/html; charset=windows-1252")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString([]byte("\x80 5")))}, header, 0)
+	assert.Equal(t, "€ 5", body.Content)
+
+	header.Set("Content-Type", "text/plain; charset=utf-16")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString([]byte("\xfe\xff\x00h\x00i")))}, header, 0)
+	assert.Equal(t, "text", body.ContentEncoding)
+	assert.Equal(t, "hi", body.Content)
+
+	header.Set("Content-Type", "text/html; charset=windows-1251")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString([]byte("\xcf\xf0\xe8\xe2\xe5\xf2")))}, header, 0)
+	assert.Equal(t, "text", body.ContentEncoding)
+	assert.Equal(t, "Привет", body.Content)
+
+	header.Set("Content-Type", "text/html; charset=koi8-r")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString([]byte("\xf0\xd2\xc9\xd7\xc5\xd4")))}, header, 0)
+	assert.Equal(t, "Привет", body.Content)
+
+	header.Set("Content-Type", "text/html; charset=cp866")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString([]byte("\x8f\xe0\xa8\xa2\xa5\xe2")))}, header, 0)
+	assert.Equal(t, "Привет", body.Content)
+
+	header.Set("Content-Type", "text/html; charset=x-cp1251")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString([]byte("\xcf\xf0\xe8\xe2\xe5\xf2")))}, header, 0)
+	assert.Equal(t, "Привет", body.Content)
+
+	// multibyte cjk charsets aren't supported, the body is kept
+	header.Set("Content-Type", "text/html; charset=Shift_JIS")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString([]byte("\x93\xfa\x96\x7b")))}, header, 0)
+	assert.Equal(t, "base64", body.ContentEncoding)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("\x93\xfa\x96\x7b")), body.Content)
+
+	// binary data is kept as base64 whatever charset is declared
+	header.Set("Content-Type", "text/html; charset=windows-1251")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString([]byte("\x00\x01\x02\x03")))}, header, 0)
+	assert.Equal(t, "base64", body.ContentEncoding)
+
+	// invalid utf-8
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	body = decodeBody(&HttpBody{ContentEncoding: "base64", Content: []byte(base64.StdEncoding.EncodeToString([]byte("caf\xe9")))}, header, 0)
+	assert.Equal(t, "base64", body.ContentEncoding)
+}
@@ -0,0 +1,253 @@
+package w3af
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/facebookgo/stackerr"
+)
+
+// mangos accepts messages up to 1mb by default, the rest is left for the transport envelope
+const defaultChunkBytes = 768 * 1024
+
+type chunkConf struct {
+	MaxBytes int `json:"maxBytes"` // size of one report in json, 768kb by default
+	MaxBody  int `json:"maxBody"`  // http bodies are truncated to this size, 0 keeps them
+}
+
+func (c *chunkConf) maxBytes() int {
+	if c != nil && c.MaxBytes > 0 {
+		return c.MaxBytes
+	}
+	return defaultChunkBytes
+}
+
+// chunkHeader is put to the raw field of every chunk when the report is split,
+// the report model doesn't have other place for it
+type chunkHeader struct {
+	Chunk  int `json:"chunk"` // from 1
+	Chunks int `json:"chunks"`
+}
+
+// the biggest header, its size is reserved in every chunk
+var maxChunkHeader = &chunkHeader{Chunk: 999999, Chunks: 999999}
+
+func (h *chunkHeader) String() string {
+	data, _ := json.Marshal(h)
+	return string(data)
+}
+
+// chunkReport splits issues report to reports which fit the byte budget.
+// Issues keep their order and the chunks are sent in order, so concatenated chunks are the original report.
+// Every chunk has a header with its number and total number of chunks.
+// An issue bigger than the budget is an error, fitIssues should be used before.
+// Issues of multi report are chunked the same way, the last chunk is sent with other sub reports
+// in the final multi report if it fits the budget.
+func chunkReport(rep *report.Report, conf *chunkConf) ([]*report.Report, error) {
+	chunks, err := splitReport(rep, conf.maxBytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) > 1 {
+		for i, chunk := range chunks {
+			chunk.Raw.Raw = (&chunkHeader{Chunk: i + 1, Chunks: len(chunks)}).String()
+		}
+	}
+	return chunks, nil
+}
+
+func splitReport(rep *report.Report, maxBytes int) ([]*report.Report, error) {
+	switch rep.Type {
+	case report.TypeIssues:
+		return chunkIssues(rep.Issues, maxBytes)
+	case report.TypeMulti:
+	default:
+		return []*report.Report{rep}, nil
+	}
+	issues, others := []*issue.Issue{}, []*report.Report{}
+	for _, subReport := range rep.Multi {
+		if subReport.Type == report.TypeIssues {
+			issues = append(issues, subReport.Issues...)
+		} else {
+			others = append(others, subReport)
+		}
+	}
+	if len(issues) == 0 {
+		return []*report.Report{rep}, nil
+	}
+	chunks, err := chunkIssues(issues, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	final := &report.Report{Type: report.TypeMulti, Multi: append([]*report.Report{chunks[len(chunks)-1]}, others...)}
+	final.Raw.Raw = maxChunkHeader.String()
+	data, err := json.Marshal(final)
+	final.Raw.Raw = ""
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	if len(data) > maxBytes {
+		final.Multi = others
+	} else {
+		chunks = chunks[:len(chunks)-1]
+	}
+	return append(chunks, final), nil
+}
+
+// chunkOverhead is a size of issues report without issues, but with the biggest chunk header
+func chunkOverhead() (int, error) {
+	empty, err := json.Marshal(&report.Report{Type: report.TypeIssues, Raw: report.Raw{Raw: maxChunkHeader.String()}})
+	if err != nil {
+		return 0, stackerr.Wrap(err)
+	}
+	// "issues":[] and commas between issues
+	return len(empty) + len(`,"issues":[]`), nil
+}
+
+func chunkIssues(issues []*issue.Issue, maxBytes int) ([]*report.Report, error) {
+	overhead, err := chunkOverhead()
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := []*report.Report{}
+	current, size := []*issue.Issue{}, overhead
+	for _, issueObj := range issues {
+		data, err := json.Marshal(issueObj)
+		if err != nil {
+			return nil, stackerr.Wrap(err)
+		}
+		issueSize := len(data) + 1
+		if overhead+issueSize > maxBytes {
+			return nil, stackerr.Newf("issue %q is %d bytes and doesn't fit the report of %d bytes",
+				issueObj.Summary, issueSize, maxBytes)
+		}
+		if len(current) > 0 && size+issueSize > maxBytes {
+			chunks = append(chunks, &report.Report{Type: report.TypeIssues, Issues: current})
+			current, size = []*issue.Issue{}, overhead
+		}
+		current = append(current, issueObj)
+		size += issueSize
+	}
+	if len(current) > 0 || len(chunks) == 0 {
+		chunks = append(chunks, &report.Report{Type: report.TypeIssues, Issues: current})
+	}
+	return chunks, nil
+}
+
+// truncateIssues cuts http bodies of issues to the configured size and returns number of truncated bodies
+func truncateIssues(issues []*issue.Issue, conf *chunkConf) int {
+	if conf == nil || conf.MaxBody <= 0 {
+		return 0
+	}
+	truncated := 0
+	for _, issueObj := range issues {
+		truncated += truncateBodies(issueObj, conf.MaxBody)
+	}
+	return truncated
+}
+
+// fitIssues truncates http bodies of issues which don't fit one chunk to the biggest size at which they fit.
+// It returns number of truncated bodies or an error if an issue doesn't fit even with empty bodies.
+func fitIssues(issues []*issue.Issue, conf *chunkConf) (int, error) {
+	overhead, err := chunkOverhead()
+	if err != nil {
+		return 0, err
+	}
+	budget := conf.maxBytes() - overhead - 1
+	truncated := 0
+	for _, issueObj := range issues {
+		n, err := fitIssue(issueObj, budget)
+		if err != nil {
+			return truncated, err
+		}
+		truncated += n
+	}
+	return truncated, nil
+}
+
+func fitIssue(issueObj *issue.Issue, maxBytes int) (int, error) {
+	bodies := []*issue.HttpBody{}
+	contents := []string{}
+	longest := 0
+	if issueObj.Vector != nil {
+		for _, trans := range issueObj.Vector.HttpTransactions {
+			for _, entity := range []*issue.HttpEntity{trans.Request, trans.Response} {
+				if entity == nil || entity.Body == nil {
+					continue
+				}
+				bodies = append(bodies, entity.Body)
+				contents = append(contents, entity.Body.Content)
+				if len(entity.Body.Content) > longest {
+					longest = len(entity.Body.Content)
+				}
+			}
+		}
+	}
+	// size of the issue with bodies cut to maxBody, original bodies are kept for the next try
+	size := func(maxBody int) (int, int, error) {
+		for i, body := range bodies {
+			body.Content = contents[i]
+		}
+		truncated := truncateBodies(issueObj, maxBody)
+		data, err := json.Marshal(issueObj)
+		return len(data), truncated, stackerr.Wrap(err)
+	}
+	issueSize, _, err := size(longest)
+	if err != nil || issueSize <= maxBytes {
+		return 0, err
+	}
+	if issueSize, _, err = size(0); err != nil {
+		return 0, err
+	}
+	if issueSize > maxBytes {
+		return 0, stackerr.Newf("issue %q is %d bytes without http bodies and doesn't fit the report of %d bytes",
+			issueObj.Summary, issueSize, maxBytes)
+	}
+	// the biggest body size at which the issue fits
+	low, high := 0, longest
+	for low < high {
+		mid := (low + high + 1) / 2
+		if issueSize, _, err = size(mid); err != nil {
+			return 0, err
+		}
+		if issueSize <= maxBytes {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	_, truncated, err := size(low)
+	return truncated, err
+}
+
+// truncateBodies cuts request and response bodies of the issue at rune boundary and returns number of cut bodies
+func truncateBodies(issueObj *issue.Issue, maxBody int) int {
+	if issueObj.Vector == nil {
+		return 0
+	}
+	truncated := 0
+	for _, trans := range issueObj.Vector.HttpTransactions {
+		for _, entity := range []*issue.HttpEntity{trans.Request, trans.Response} {
+			if entity == nil || entity.Body == nil || len(entity.Body.Content) <= maxBody {
+				continue
+			}
+			truncated++
+			content := entity.Body.Content
+			if entity.Body.ContentEncoding == encodingBase64 {
+				// a note would break base64, so the body is only cut by 4 bytes blocks
+				entity.Body.Content = content[:maxBody-maxBody%4]
+				continue
+			}
+			cut := maxBody
+			for cut > 0 && !utf8.RuneStart(content[cut]) {
+				cut--
+			}
+			entity.Body.Content = content[:cut] + fmt.Sprintf("\n[truncated %d bytes]", len(content)-cut)
+		}
+	}
+	return truncated
+}
@@ -0,0 +1,204 @@
+package w3af
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func testIssues(n int) []*issue.Issue {
+	issues := []*issue.Issue{}
+	for i := 0; i < n; i++ {
+		trans := newTestTransaction("GET /?id=1 HTTP/1.1", "HTTP/1.1 200 OK", strings.Repeat("x", 100+i%50))
+		issues = append(issues, &issue.Issue{
+			UniqId:   fmt.Sprintf("%d", i),
+			Summary:  "Sql injection",
+			Severity: issue.SeverityHigh,
+			Vector:   &issue.Vector{HttpTransactions: []*issue.HttpTransaction{trans}},
+		})
+	}
+	return issues
+}
+
+func TestChunkReport(t *testing.T) {
+	issues := testIssues(1000)
+	rep := &report.Report{Type: report.TypeIssues, Issues: issues}
+	conf := &chunkConf{MaxBytes: 16 * 1024}
+	chunks, err := chunkReport(rep, conf)
+	require.NoError(t, err)
+	require.True(t, len(chunks) > 1)
+
+	// every issue is sent once and in order
+	sent := []*issue.Issue{}
+	for i, chunk := range chunks {
+		assert.Equal(t, report.TypeIssues, chunk.Type)
+		data, err := json.Marshal(chunk)
+		require.NoError(t, err)
+		assert.True(t, len(data) <= conf.MaxBytes, "chunk is %d bytes", len(data))
+		assert.Equal(t, fmt.Sprintf(`{"chunk":%d,"chunks":%d}`, i+1, len(chunks)), chunk.Raw.Raw)
+		sent = append(sent, chunk.Issues...)
+	}
+	require.Len(t, sent, len(issues))
+	for i := range issues {
+		assert.Equal(t, issues[i].UniqId, sent[i].UniqId)
+	}
+
+	// chunks are the same every time
+	again, err := chunkReport(&report.Report{Type: report.TypeIssues, Issues: issues}, conf)
+	require.NoError(t, err)
+	require.Len(t, again, len(chunks))
+	for i := range chunks {
+		assert.Equal(t, chunks[i].Issues, again[i].Issues)
+	}
+
+	// small report isn't split and doesn't have a header
+	chunks, err = chunkReport(rep, nil)
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, rep, chunks[0])
+	assert.Empty(t, chunks[0].Raw.Raw)
+
+	// other reports are sent as is
+	empty := &report.Report{Type: report.TypeEmpty}
+	chunks, err = chunkReport(empty, conf)
+	require.NoError(t, err)
+	assert.Equal(t, []*report.Report{empty}, chunks)
+}
+
+func TestChunkMultiReport(t *testing.T) {
+	summary := &report.Report{Type: report.TypeRaw, Raw: report.Raw{Raw: "summary"}}
+	issues := testIssues(1000)
+	rep := &report.Report{Type: report.TypeMulti, Multi: []*report.Report{
+		&report.Report{Type: report.TypeIssues, Issues: issues},
+		summary,
+	}}
+	conf := &chunkConf{MaxBytes: 16 * 1024}
+	chunks, err := chunkReport(rep, conf)
+	require.NoError(t, err)
+	require.True(t, len(chunks) > 1)
+	sent := []*issue.Issue{}
+	for _, chunk := range chunks[:len(chunks)-1] {
+		assert.Equal(t, report.TypeIssues, chunk.Type)
+		sent = append(sent, chunk.Issues...)
+	}
+	// the last issues are sent with the summary
+	final := chunks[len(chunks)-1]
+	assert.Equal(t, report.TypeMulti, final.Type)
+	assert.Equal(t, fmt.Sprintf(`{"chunk":%d,"chunks":%d}`, len(chunks), len(chunks)), final.Raw.Raw)
+	require.Len(t, final.Multi, 2)
+	assert.Equal(t, summary, final.Multi[1])
+	data, err := json.Marshal(final)
+	require.NoError(t, err)
+	assert.True(t, len(data) <= conf.MaxBytes, "final report is %d bytes", len(data))
+	sent = append(sent, final.GetAllIssues()...)
+	assert.Equal(t, issues, sent)
+
+	// small report isn't split
+	chunks, err = chunkReport(rep, nil)
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, rep.Multi, chunks[0].Multi)
+
+	// the last chunk doesn't fit with the summary
+	summary.Raw.Raw = strings.Repeat("x", 16*1024)
+	chunks, err = chunkReport(rep, conf)
+	require.NoError(t, err)
+	assert.Equal(t, []*report.Report{summary}, chunks[len(chunks)-1].Multi)
+	sent = []*issue.Issue{}
+	for _, chunk := range chunks {
+		sent = append(sent, chunk.GetAllIssues()...)
+	}
+	assert.Equal(t, issues, sent)
+}
+
+func TestChunkReportBigIssue(t *testing.T) {
+	issues := testIssues(3)
+	issues[1].Vector.HttpTransactions[0].Response.Body.Content = strings.Repeat("ф", 10000)
+	// the big issue doesn't fit
+	_, err := chunkReport(&report.Report{Type: report.TypeIssues, Issues: issues}, &chunkConf{MaxBytes: 4096})
+	assert.Error(t, err)
+
+	// bodies are truncated
+	conf := &chunkConf{MaxBytes: 4096, MaxBody: 101}
+	assert.Equal(t, 2, truncateIssues(issues, conf))
+	chunks, err := chunkReport(&report.Report{Type: report.TypeIssues, Issues: issues}, conf)
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	body := issues[1].Vector.HttpTransactions[0].Response.Body.Content
+	assert.Equal(t, strings.Repeat("ф", 50)+"\n[truncated 19900 bytes]", body)
+	assert.Equal(t, strings.Repeat("x", 101)+"\n[truncated 1 bytes]", issues[2].Vector.HttpTransactions[0].Response.Body.Content)
+}
+
+func TestFitIssues(t *testing.T) {
+	issues := testIssues(3)
+	issues[1].Vector.HttpTransactions[0].Response.Body.Content = strings.Repeat("ф", 10000)
+	issues[1].Desc = "evidence"
+	conf := &chunkConf{MaxBytes: 4096}
+	truncated, err := fitIssues(issues, conf)
+	require.NoError(t, err)
+	assert.Equal(t, 1, truncated)
+	// small issues aren't changed, description is kept
+	assert.Equal(t, strings.Repeat("x", 100), issues[0].Vector.HttpTransactions[0].Response.Body.Content)
+	assert.Equal(t, "evidence", issues[1].Desc)
+
+	// the big issue is cut to fit the chunk
+	body := issues[1].Vector.HttpTransactions[0].Response.Body.Content
+	assert.Contains(t, body, "[truncated")
+	assert.True(t, len(body) > 3000, "body is cut to %d bytes", len(body))
+	chunks, err := chunkReport(&report.Report{Type: report.TypeIssues, Issues: issues}, conf)
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	for _, chunk := range chunks {
+		data, err := json.Marshal(chunk)
+		require.NoError(t, err)
+		assert.True(t, len(data) <= conf.MaxBytes, "chunk is %d bytes", len(data))
+	}
+
+	// the issue doesn't fit even without bodies
+	issues = testIssues(1)
+	issues[0].Desc = strings.Repeat("x", 5000)
+	_, err = fitIssues(issues, conf)
+	assert.Error(t, err)
+}
+
+func TestTruncateBase64Body(t *testing.T) {
+	trans := newTestTransaction("GET / HTTP/1.1", "HTTP/1.1 200 OK", "")
+	trans.Response.Body = &issue.HttpBody{ContentEncoding: encodingBase64, Content: "AAECAwQFBgcICQ=="}
+	truncateBodies(&issue.Issue{Vector: &issue.Vector{HttpTransactions: []*issue.HttpTransaction{trans}}}, 10)
+	assert.Equal(t, "AAECAwQF", trans.Response.Body.Content)
+}
+
+func TestW3afHandleChunks(t *testing.T) {
+	conf := &plan.Conf{
+		Target:   "http://192.168.1.35:8082/",
+		FormData: `{"type": "plan", "data": "[audit]\nxss", "chunk": {"maxBytes": 8192, "maxBody": 2048}}`,
+	}
+	s := newTestW3af(t)
+	s.retry = testRetryPolicy
+	client := &flakyClient{}
+	require.NoError(t, s.Handle(context.Background(), client, conf))
+	require.True(t, len(client.Reports) > 1)
+
+	// the same issues as in one report, bodies are cut the same way, so no issue is fitted to the chunk
+	conf.FormData = `{"type": "plan", "data": "[audit]\nxss", "chunk": {"maxBody": 2048}}`
+	single := &flakyClient{}
+	require.NoError(t, s.Handle(context.Background(), single, conf))
+	require.Len(t, single.Reports, 1)
+	sent := []*issue.Issue{}
+	for _, rep := range client.Reports {
+		sent = append(sent, rep.GetAllIssues()...)
+	}
+	assert.Equal(t, single.Reports[0].GetAllIssues(), sent)
+	// summary is in the final report
+	final := client.Reports[len(client.Reports)-1]
+	assert.Equal(t, report.TypeMulti, final.Type)
+	assert.Equal(t, report.TypeRaw, final.Multi[len(final.Multi)-1].Type)
+}
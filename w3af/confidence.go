@@ -0,0 +1,190 @@
+package w3af
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
+
+// confidence levels
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
+const confidenceExtra = "Confidence: "
+
+var confidenceSummary = regexp.MustCompile(`^\[(low|medium|high) confidence\] `)
+
+var confidenceOrder = map[string]int{
+	ConfidenceLow:    1,
+	ConfidenceMedium: 2,
+	ConfidenceHigh:   3,
+}
+
+// base confidence score by w3af audit plugin, 1 by default
+var pluginConfidence = map[string]int{
+	// error and content based checks
+	"sqli":          2,
+	"xss":           2,
+	"os_commanding": 2,
+	"lfi":           2,
+	"rfi":           2,
+	"eval":          2,
+	"xpath":         2,
+	"ldapi":         2,
+	"ssi":           2,
+	// timing and heuristic based checks
+	"blind_sqli":      0,
+	"buffer_overflow": 0,
+	"format_string":   0,
+	"generic":         0,
+}
+
+// plugins which find server errors, so 5xx status confirms the finding
+var errorPlugins = map[string]bool{
+	"sqli":  true,
+	"xpath": true,
+	"ldapi": true,
+	"eval":  true,
+}
+
+// payloads shorter than this are reflected by accident
+const minReflectedLen = 4
+
+type confidenceConf struct {
+	Disabled bool   `json:"disabled"`
+	Min      string `json:"min"` // minimum confidence level, findings with lower confidence are dropped
+}
+
+// confidenceScorer computes confidence level of every finding
+type confidenceScorer struct {
+	min     int
+	metrics findingMetrics
+
+	// number of findings dropped by minimum confidence
+	Filtered int
+}
+
+func newConfidenceScorer(conf *confidenceConf) (*confidenceScorer, error) {
+	s := &confidenceScorer{metrics: scanMetrics{}}
+	if conf != nil && conf.Min != "" {
+		min, ok := confidenceOrder[strings.ToLower(conf.Min)]
+		if !ok {
+			return nil, fmt.Errorf("minimum confidence should be one of low, medium, high, but got %s", conf.Min)
+		}
+		s.min = min
+	}
+	return s, nil
+}
+
+// Process implements findingProcessor
+func (s *confidenceScorer) Process(vuln *Vulnerability, issueObj *issue.Issue) bool {
+	level := findingConfidence(vuln.Plugin, vuln.Var, issueObj)
+	if confidenceOrder[level] < s.min {
+		s.Filtered++
+		s.metrics.Dropped(dropLowConfidence)
+		return false
+	}
+	markConfidence(issueObj, level)
+	return true
+}
+
+// markConfidence adds confidence level to the summary and extras
+func markConfidence(issueObj *issue.Issue, level string) {
+	issueObj.Summary = fmt.Sprintf("[%s confidence] %s", level, issueObj.Summary)
+	issueObj.Extras = append(issueObj.Extras, &issue.Extra{Title: confidenceExtra + level})
+}
+
+// unmarkConfidence removes confidence level added by markConfidence and returns it or empty string
+func unmarkConfidence(issueObj *issue.Issue) string {
+	match := confidenceSummary.FindStringSubmatch(issueObj.Summary)
+	if match == nil {
+		return ""
+	}
+	issueObj.Summary = strings.TrimPrefix(issueObj.Summary, match[0])
+	extras := []*issue.Extra{}
+	for _, extra := range issueObj.Extras {
+		if !strings.HasPrefix(extra.Title, confidenceExtra) {
+			extras = append(extras, extra)
+		}
+	}
+	issueObj.Extras = extras
+	return match[1]
+}
+
+// FilteredIssue returns info issue about findings with low confidence or nil if nothing is filtered
+func (s *confidenceScorer) FilteredIssue() *issue.Issue {
+	if s.Filtered == 0 {
+		return nil
+	}
+	return &issue.Issue{
+		Severity: issue.SeverityInfo,
+		Summary:  fmt.Sprintf("%d w3af findings with low confidence were dropped", s.Filtered),
+	}
+}
+
+// findingConfidence returns confidence level based on the plugin, reflected payload,
+// number of transactions and response status codes
+func findingConfidence(plugin, param string, issueObj *issue.Issue) string {
+	score, ok := pluginConfidence[plugin]
+	if !ok {
+		score = 1
+	}
+	var transactions []*issue.HttpTransaction
+	if issueObj.Vector != nil {
+		transactions = issueObj.Vector.HttpTransactions
+	}
+	if len(transactions) > 1 {
+		score++
+	}
+	reflected, serverError, notFound, responses := false, false, 0, 0
+	for _, trans := range transactions {
+		if trans.Response == nil {
+			continue
+		}
+		responses++
+		status := responseStatus(trans.Response)
+		if status >= 500 {
+			serverError = true
+		} else if status == 404 {
+			notFound++
+		}
+		if param == "" || trans.Request == nil || trans.Response.Body == nil {
+			continue
+		}
+		if value, ok := requestParamValue(trans.Request, param); ok && len(value) >= minReflectedLen &&
+			strings.Contains(trans.Response.Body.Content, value) {
+			reflected = true
+		}
+	}
+	if reflected {
+		score++
+	}
+	if serverError && errorPlugins[plugin] {
+		score++
+	}
+	if responses == 0 || notFound == responses {
+		score--
+	}
+	switch {
+	case score <= 1:
+		return ConfidenceLow
+	case score == 2:
+		return ConfidenceMedium
+	}
+	return ConfidenceHigh
+}
+
+// responseStatus parses status code from "HTTP/1.1 200 OK"
+func responseStatus(resp *issue.HttpEntity) int {
+	code := 0
+	fields := strings.Fields(resp.Status)
+	if len(fields) > 1 {
+		fmt.Sscanf(fields[1], "%d", &code)
+	}
+	return code
+}
@@ -0,0 +1,74 @@
+package w3af
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTransaction(requestStatus, responseStatus, responseBody string) *issue.HttpTransaction {
+	return &issue.HttpTransaction{
+		Request: &issue.HttpEntity{Status: requestStatus, Header: http.Header{}},
+		Response: &issue.HttpEntity{
+			Status: responseStatus,
+			Header: http.Header{},
+			Body:   &issue.HttpBody{ContentEncoding: "text", Content: responseBody},
+		},
+	}
+}
+
+func TestFindingConfidence(t *testing.T) {
+	reflected := newTestTransaction("GET /?q=%3Cscript%3E HTTP/1.1", "HTTP/1.1 200 OK", "<p><script></p>")
+	notReflected := newTestTransaction("GET /?q=%3Cscript%3E HTTP/1.1", "HTTP/1.1 200 OK", "<p>&lt;script&gt;</p>")
+	serverError := newTestTransaction("GET /?id=1%27 HTTP/1.1", "HTTP/1.1 500 Internal Server Error", "SQL syntax")
+	notFound := newTestTransaction("GET /?id=1 HTTP/1.1", "HTTP/1.1 404 Not Found", "")
+
+	issueWith := func(transactions ...*issue.HttpTransaction) *issue.Issue {
+		return &issue.Issue{Vector: &issue.Vector{HttpTransactions: transactions}}
+	}
+
+	assert.Equal(t, ConfidenceHigh, findingConfidence("xss", "q", issueWith(reflected)))
+	assert.Equal(t, ConfidenceMedium, findingConfidence("xss", "q", issueWith(notReflected)))
+	assert.Equal(t, ConfidenceHigh, findingConfidence("sqli", "id", issueWith(serverError)))
+	assert.Equal(t, ConfidenceLow, findingConfidence("blind_sqli", "id", issueWith(serverError)))
+	assert.Equal(t, ConfidenceLow, findingConfidence("blind_sqli", "id", issueWith(serverError, serverError, serverError)))
+	assert.Equal(t, ConfidenceLow, findingConfidence("unknown", "id", issueWith(notFound)))
+	assert.Equal(t, ConfidenceLow, findingConfidence("unknown", "", &issue.Issue{}))
+	assert.Equal(t, ConfidenceMedium, findingConfidence("unknown", "id", issueWith(notFound, serverError)))
+}
+
+func TestConfidenceScorer(t *testing.T) {
+	xmlReport, err := parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+
+	s, err := newConfidenceScorer(nil)
+	require.NoError(t, err)
+	issues, err := transformXmlReport(xmlReport, nil, 0, s)
+	require.NoError(t, err)
+	require.Len(t, issues, 23)
+	assert.Equal(t, "[high confidence] Cross site scripting vulnerability", issues[2].Summary)
+	require.Len(t, issues[2].Extras, 2)
+	assert.Equal(t, "Injected parameter: in", issues[2].Extras[0].Title)
+	assert.Equal(t, "Confidence: high", issues[2].Extras[1].Title)
+	assert.Nil(t, s.FilteredIssue())
+
+	// everything below high is filtered
+	xmlReport, err = parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+	xmlReport.Vulnerabilities[0].Plugin = "blind_sqli"
+	s, err = newConfidenceScorer(&confidenceConf{Min: "High"})
+	require.NoError(t, err)
+	issues, err = transformXmlReport(xmlReport, nil, 0, s)
+	require.NoError(t, err)
+	assert.True(t, s.Filtered > 0)
+	assert.Len(t, issues, 23-s.Filtered)
+	filtered := s.FilteredIssue()
+	require.NotNil(t, filtered)
+	assert.Equal(t, issue.SeverityInfo, filtered.Severity)
+
+	_, err = newConfidenceScorer(&confidenceConf{Min: "certain"})
+	assert.Error(t, err)
+}
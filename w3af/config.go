@@ -0,0 +1,138 @@
+package w3af
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bearded-web/bearded/pkg/script"
+)
+
+// Config describes w3af tool and its container layout
+type Config struct {
+	Tool        string   // name of w3af plugin
+	Version     string   // plugin version, 1.6.* matches by prefix, the latest version is used if empty
+	HomeDir     string   // w3af writes xml report here
+	ShareDir    string   // shared files are mounted here
+	ReportName  string   // name of xml report
+	ProfileName string   // name of shared profile
+	ExtraArgs   []string // appended to w3af command args
+
+	RetryDeadline time.Duration // agent calls, e.g. the final report, are retried for this time, 5 minutes by default
+}
+
+// DefaultConfig returns config for barbudo/w3af image
+func DefaultConfig() *Config {
+	return &Config{
+		Tool:        "barbudo/w3af",
+		HomeDir:     "/home/app",
+		ShareDir:    "/share",
+		ReportName:  "report.xml",
+		ProfileName: "profile.pw3af",
+	}
+}
+
+type Option func(*Config)
+
+func WithTool(name, version string) Option {
+	return func(c *Config) {
+		c.Tool, c.Version = name, version
+	}
+}
+
+func WithDirs(homeDir, shareDir string) Option {
+	return func(c *Config) {
+		c.HomeDir, c.ShareDir = homeDir, shareDir
+	}
+}
+
+func WithFileNames(reportName, profileName string) Option {
+	return func(c *Config) {
+		c.ReportName, c.ProfileName = reportName, profileName
+	}
+}
+
+func WithRetryDeadline(deadline time.Duration) Option {
+	return func(c *Config) {
+		c.RetryDeadline = deadline
+	}
+}
+
+func WithExtraArgs(args ...string) Option {
+	return func(c *Config) {
+		c.ExtraArgs = append(c.ExtraArgs, args...)
+	}
+}
+
+var toolRe = regexp.MustCompile(`^[\w.-]+(/[\w.-]+)*$`)
+
+// Validate checks that config makes sense
+func (c *Config) Validate() error {
+	if !toolRe.MatchString(c.Tool) {
+		return fmt.Errorf("bad w3af tool name %q", c.Tool)
+	}
+	if strings.Contains(strings.TrimSuffix(c.Version, "*"), "*") {
+		return fmt.Errorf("bad w3af version %q, only trailing * is allowed", c.Version)
+	}
+	for _, dir := range []string{c.HomeDir, c.ShareDir} {
+		if !path.IsAbs(dir) {
+			return fmt.Errorf("container dir should be absolute, but got %q", dir)
+		}
+	}
+	for _, name := range []string{c.ReportName, c.ProfileName} {
+		if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+			return fmt.Errorf("bad file name %q", name)
+		}
+	}
+	if c.ReportName == c.ProfileName {
+		return fmt.Errorf("report and profile should have different names")
+	}
+	for _, arg := range c.ExtraArgs {
+		if arg == "" {
+			return fmt.Errorf("extra args shouldn't be empty")
+		}
+		if name := argName(arg); reservedArgs[name] {
+			return fmt.Errorf("w3af arg %s is set by the script and can't be in extra args", name)
+		}
+	}
+	if c.RetryDeadline < 0 {
+		return fmt.Errorf("retry deadline shouldn't be negative")
+	}
+	return nil
+}
+
+// ReportPath is a path of xml report in the container
+func (c *Config) ReportPath() string {
+	return path.Join(c.HomeDir, c.ReportName)
+}
+
+// ProfilePath is a path of shared profile in the container
+func (c *Config) ProfilePath() string {
+	return path.Join(c.ShareDir, c.ProfileName)
+}
+
+// pluginVersion returns the latest plugin version which matches the config version or empty string
+func (c *Config) pluginVersion(pl *script.Plugin) string {
+	for i := len(pl.Versions) - 1; i >= 0; i-- {
+		version := pl.Versions[i]
+		if c.Version == "" || version == c.Version ||
+			strings.HasSuffix(c.Version, "*") && strings.HasPrefix(version, strings.TrimSuffix(c.Version, "*")) {
+			return version
+		}
+	}
+	return ""
+}
+
+// quoteArgs joins command args, args with spaces or quotes are single quoted
+func quoteArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`") {
+			arg = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
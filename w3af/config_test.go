@@ -0,0 +1,114 @@
+package w3af
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/bearded-web/bearded/pkg/script"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, DefaultConfig().Validate())
+
+	bad := []Option{
+		WithTool("", ""),
+		WithTool("my w3af", ""),
+		WithTool("acme/w3af", "1.*.1"),
+		WithDirs("home", "/share"),
+		WithDirs("/home/app", ""),
+		WithFileNames("", "profile.pw3af"),
+		WithFileNames("out/report.xml", "profile.pw3af"),
+		WithFileNames("report.xml", ".."),
+		WithFileNames("w3af.xml", "w3af.xml"),
+		WithExtraArgs("-n", ""),
+		WithExtraArgs("-P", "/tmp/other.pw3af"),
+		WithExtraArgs("--profile=/tmp/other.pw3af"),
+		WithRetryDeadline(-time.Second),
+	}
+	for i, opt := range bad {
+		_, err := NewW3af(opt)
+		assert.Error(t, err, "option %d", i)
+	}
+	s, err := NewW3af(WithTool("acme/w3af-hardened", "1.6.*"), WithExtraArgs("-n"))
+	require.NoError(t, err)
+	assert.Equal(t, "acme/w3af-hardened", s.conf.Tool)
+	assert.Equal(t, []string{"-n"}, s.conf.ExtraArgs)
+	assert.Equal(t, defaultRetryPolicy.MaxElapsed, s.retry.MaxElapsed)
+
+	// the default policy isn't changed
+	s, err = NewW3af(WithRetryDeadline(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, s.retry.MaxElapsed)
+	assert.Equal(t, 5*time.Minute, defaultRetryPolicy.MaxElapsed)
+}
+
+func TestConfigPluginVersion(t *testing.T) {
+	pl := script.NewPlugin("w3af", nil, "1.6.48", "1.6.49", "1.7.0")
+	for version, expected := range map[string]string{
+		"":       "1.7.0",
+		"1.6.48": "1.6.48",
+		"1.6.*":  "1.6.49",
+		"1.*":    "1.7.0",
+		"2.*":    "",
+		"1.6":    "",
+	} {
+		conf := DefaultConfig()
+		conf.Version = version
+		assert.Equal(t, expected, conf.pluginVersion(pl), "version %q", version)
+	}
+}
+
+func TestQuoteArgs(t *testing.T) {
+	assert.Equal(t, "-P /share/profile.pw3af -n", quoteArgs([]string{"-P", "/share/profile.pw3af", "-n"}))
+	assert.Equal(t, `-P '/my share/profile' 'it'\''s' ''`, quoteArgs([]string{"-P", "/my share/profile", "it's", ""}))
+	assert.Equal(t, "", quoteArgs(nil))
+}
+
+func TestW3afHandleConfig(t *testing.T) {
+	conf := &plan.Conf{
+		Target:   "http://192.168.1.35:8082/",
+		FormData: `{"type": "plan", "data": "[audit]\nxss"}`,
+	}
+	bg := context.Background()
+
+	// defaults are the same as before options
+	client := &flakyClient{}
+	require.NoError(t, newTestW3af(t).Handle(bg, client, conf))
+	require.Len(t, client.Steps, 1)
+	step := client.Steps[0]
+	assert.Equal(t, "barbudo/w3af:0.0.1", step.Plugin)
+	assert.Equal(t, "-P /share/profile.pw3af", step.Conf.CommandArgs)
+	assert.Equal(t, []*plan.File{&plan.File{Path: "/home/app/report.xml", Name: "report.xml"}}, step.Conf.TakeFiles)
+	require.Len(t, step.Conf.SharedFiles, 1)
+	assert.Equal(t, "profile.pw3af", step.Conf.SharedFiles[0].Path)
+	assert.Contains(t, step.Conf.SharedFiles[0].Text, "output_file = /home/app/report.xml")
+
+	s := newTestW3af(t,
+		WithTool("acme/w3af", "0.0.*"),
+		WithDirs("/opt/w3af", "/mnt/shared files"),
+		WithFileNames("w3af.xml", "scan.pw3af"),
+		WithExtraArgs("--no-color"),
+	)
+	client = &flakyClient{}
+	require.NoError(t, s.Handle(bg, client, conf))
+	require.Len(t, client.Steps, 1)
+	step = client.Steps[0]
+	assert.Equal(t, "acme/w3af:0.0.1", step.Plugin)
+	assert.Equal(t, "-P '/mnt/shared files/scan.pw3af' --no-color", step.Conf.CommandArgs)
+	assert.Equal(t, []*plan.File{&plan.File{Path: "/opt/w3af/w3af.xml", Name: "w3af.xml"}}, step.Conf.TakeFiles)
+	assert.Equal(t, "scan.pw3af", step.Conf.SharedFiles[0].Path)
+	assert.Contains(t, step.Conf.SharedFiles[0].Text, "output_file = /opt/w3af/w3af.xml")
+	require.Len(t, client.Reports, 1)
+
+	// version isn't available
+	s = newTestW3af(t, WithTool("acme/w3af", "1.*"))
+	client = &flakyClient{}
+	err := s.Handle(bg, client, conf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `acme/w3af has no version matching "1.*"`)
+	assert.Len(t, client.Steps, 0)
+}
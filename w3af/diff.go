@@ -0,0 +1,101 @@
+package w3af
+
+import (
+	"fmt"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/bearded-web/bearded/pkg/script"
+	"github.com/facebookgo/stackerr"
+	"golang.org/x/net/context"
+)
+
+// diff tags
+const (
+	DiffNew        = "New since the previous scan"
+	DiffPersisting = "Persisting since the previous scan"
+)
+
+type diffConf struct {
+	PreviousReport string         `json:"previousReport"` // file id of report.xml from the previous scan
+	PreviousIssues []*issue.Issue `json:"previousIssues"` // or issues from the previous scan
+}
+
+// getPreviousIssues returns issues of the previous scan with fingerprints,
+// previous report.xml is transformed in the same way as the current one,
+// so findings dropped by the current filters aren't reported as fixed.
+func getPreviousIssues(ctx context.Context, client script.ClientV1, conf *diffConf, group *groupConf, filters *scanFilters) ([]*issue.Issue, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	issues := append([]*issue.Issue{}, conf.PreviousIssues...)
+	if conf.PreviousReport == "" {
+		return issues, nil
+	}
+	data, err := client.DownloadFile(ctx, conf.PreviousReport)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	xmlReport, err := parseXml(data)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	// findings of the previous scan aren't counted in metrics
+	filters = filters.Fresh(noopMetrics{})
+	processors := append([]findingProcessor{processorFunc(setFingerprint)}, filters.Processors()...)
+	var grouper *grouper
+	if group != nil && group.Enabled {
+		grouper = newGrouper(group)
+		processors = append(processors, grouper)
+	}
+	prevIssues, err := transformXmlReport(xmlReport, filters.severities, 0, processors...)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	if grouper != nil {
+		grouper.Finish()
+	}
+	return append(issues, prevIssues...), nil
+}
+
+// diffIssues tags current issues as new or persisting and returns info issues for fixed findings,
+// only issues with fingerprints are compared.
+func diffIssues(current, previous []*issue.Issue) []*issue.Issue {
+	prevIds := map[string]bool{}
+	for _, prev := range previous {
+		if prev.UniqId != "" {
+			prevIds[prev.UniqId] = true
+		}
+	}
+	currentIds := map[string]bool{}
+	for _, issueObj := range current {
+		if issueObj.UniqId == "" {
+			continue
+		}
+		currentIds[issueObj.UniqId] = true
+		tag := DiffNew
+		if prevIds[issueObj.UniqId] {
+			tag = DiffPersisting
+		}
+		issueObj.Extras = append(issueObj.Extras, &issue.Extra{Title: tag})
+	}
+
+	fixed := []*issue.Issue{}
+	for _, prev := range previous {
+		if prev.UniqId == "" || currentIds[prev.UniqId] {
+			continue
+		}
+		// the same finding could be in the previous report and in the issues
+		currentIds[prev.UniqId] = true
+		fixedIssue := &issue.Issue{
+			Severity: issue.SeverityInfo,
+			Summary:  fmt.Sprintf("Fixed: %s", prev.Summary),
+			Desc: fmt.Sprintf("The finding with %s severity from the previous scan isn't found anymore.\n\n%s",
+				prev.Severity, prev.Desc),
+		}
+		if prev.Vector != nil {
+			fixedIssue.Vector = &issue.Vector{Url: prev.Vector.Url}
+		}
+		fixed = append(fixed, fixedIssue)
+	}
+	return fixed
+}
@@ -0,0 +1,128 @@
+package w3af
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestDiffIssues(t *testing.T) {
+	previous := []*issue.Issue{
+		&issue.Issue{UniqId: "1", Summary: "persisting", Severity: issue.SeverityHigh},
+		&issue.Issue{UniqId: "2", Summary: "fixed", Severity: issue.SeverityMedium, Desc: "desc",
+			Vector: &issue.Vector{Url: "http://example.com/fixed"}},
+		&issue.Issue{UniqId: "2", Summary: "fixed duplicate"},
+		&issue.Issue{Summary: "error without fingerprint", Severity: issue.SeverityError},
+	}
+	current := []*issue.Issue{
+		&issue.Issue{UniqId: "1", Summary: "persisting"},
+		&issue.Issue{UniqId: "3", Summary: "new"},
+		&issue.Issue{Summary: "error without fingerprint", Severity: issue.SeverityError},
+	}
+	fixed := diffIssues(current, previous)
+
+	require.Len(t, current[0].Extras, 1)
+	assert.Equal(t, DiffPersisting, current[0].Extras[0].Title)
+	require.Len(t, current[1].Extras, 1)
+	assert.Equal(t, DiffNew, current[1].Extras[0].Title)
+	assert.Len(t, current[2].Extras, 0)
+
+	require.Len(t, fixed, 1)
+	assert.Equal(t, issue.SeverityInfo, fixed[0].Severity)
+	assert.Equal(t, "Fixed: fixed", fixed[0].Summary)
+	assert.Contains(t, fixed[0].Desc, "with medium severity")
+	assert.Equal(t, "http://example.com/fixed", fixed[0].Vector.Url)
+}
+
+func TestGetPreviousIssues(t *testing.T) {
+	bg := context.Background()
+	client := &ClientMock{}
+	client.On("DownloadFile", bg, "prev").Return(loadTestData("report.xml"), nil).Twice()
+	client.On("DownloadFile", bg, "missing").Return([]byte(nil), fmt.Errorf("not found")).Once()
+
+	filters, err := getScanFilters(bg, client, &w3afData{})
+	require.NoError(t, err)
+
+	issues, err := getPreviousIssues(bg, client, nil, nil, filters)
+	require.NoError(t, err)
+	assert.Len(t, issues, 0)
+
+	formIssues := []*issue.Issue{&issue.Issue{UniqId: "1"}}
+	issues, err = getPreviousIssues(bg, client, &diffConf{PreviousIssues: formIssues}, nil, filters)
+	require.NoError(t, err)
+	assert.Equal(t, formIssues, issues)
+
+	issues, err = getPreviousIssues(bg, client, &diffConf{PreviousReport: "prev", PreviousIssues: formIssues}, nil, filters)
+	require.NoError(t, err)
+	require.Len(t, issues, 24)
+	assert.Equal(t, vulnFingerprint(&Vulnerability{
+		Plugin: "xss",
+		Name:   "Cross site scripting vulnerability",
+		Method: "GET",
+		Url:    "http://192.168.1.35:8082/xss/reflect/js4_dq",
+		Var:    "in",
+	}), issues[3].UniqId)
+
+	// grouped in the same way as the current report
+	grouped, err := getPreviousIssues(bg, client, &diffConf{PreviousReport: "prev"}, &groupConf{Enabled: true, IgnorePath: true}, filters)
+	require.NoError(t, err)
+	assert.True(t, len(grouped) < 10)
+
+	_, err = getPreviousIssues(bg, client, &diffConf{PreviousReport: "missing"}, nil, filters)
+	assert.Error(t, err)
+
+	client.Mock.AssertExpectations(t)
+}
+
+func TestDiffWithPreviousReport(t *testing.T) {
+	xmlReport, err := parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+	previous, err := transformXmlReport(xmlReport, nil, 0, processorFunc(setFingerprint))
+	require.NoError(t, err)
+
+	// one vulnerability is fixed, one is new
+	fixedVuln := xmlReport.Vulnerabilities[0]
+	xmlReport.Vulnerabilities[0] = &Vulnerability{Plugin: "sqli", Name: "SQL injection", Severity: SevHigh}
+	current, err := transformXmlReport(xmlReport, nil, 0, processorFunc(setFingerprint))
+	require.NoError(t, err)
+
+	fixed := diffIssues(current, previous)
+	require.Len(t, fixed, 1)
+	assert.Equal(t, "Fixed: "+fixedVuln.Name, fixed[0].Summary)
+	assert.Equal(t, DiffNew, current[2].Extras[0].Title)
+	assert.Equal(t, DiffPersisting, current[3].Extras[1].Title)
+}
+
+func TestDiffWithSuppressedFinding(t *testing.T) {
+	bg := context.Background()
+	client := &ClientMock{}
+	client.On("DownloadFile", bg, "prev").Return(loadTestData("report.xml"), nil).Once()
+
+	xmlReport, err := parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+	suppressed := xmlReport.Vulnerabilities[0]
+	filters, err := getScanFilters(bg, client, &w3afData{
+		Suppress: &suppressConf{Rules: []*suppressRule{
+			&suppressRule{Fingerprint: vulnFingerprint(suppressed), Reason: "false positive"},
+		}},
+	})
+	require.NoError(t, err)
+
+	previous, err := getPreviousIssues(bg, client, &diffConf{PreviousReport: "prev"}, nil, filters)
+	require.NoError(t, err)
+	processors := append([]findingProcessor{processorFunc(setFingerprint)}, filters.Processors()...)
+	current, err := transformXmlReport(xmlReport, filters.severities, 0, processors...)
+	require.NoError(t, err)
+	require.Len(t, current, len(previous))
+
+	// the suppressed finding isn't fixed
+	assert.Len(t, diffIssues(current, previous), 0)
+	// and counted only once
+	assert.Equal(t, 1, filters.suppressor.Suppressed[0])
+
+	client.Mock.AssertExpectations(t)
+}
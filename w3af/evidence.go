@@ -0,0 +1,143 @@
+package w3af
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
+
+const (
+	defaultEvidenceContext = 80
+	maxEvidencePerBody     = 3
+)
+
+// database error messages which are looked for in responses of error based plugins
+var sqlErrorPattern = regexp.MustCompile(`(?i)(you have an error in your sql syntax|warning: mysql_|mysql_fetch|` +
+	`ORA-\d{5}|SQLSTATE\[|unclosed quotation mark|pg_query\(\)|PostgreSQL.*ERROR|SQLite3?::|` +
+	`sqlite_error|Microsoft OLE DB Provider|ODBC.*Driver|syntax error at or near)`)
+
+type evidenceConf struct {
+	Disabled bool `json:"disabled"`
+	Context  int  `json:"context"` // number of bytes around the evidence, 80 by default
+}
+
+type evidence struct {
+	TransactionId int
+	Start, End    int // byte offsets in the redacted response body before truncation
+	Excerpt       string
+}
+
+// evidenceFinder adds excerpts of response bodies where the payload is reflected
+// or the database error appears. Transactions are remembered while processing
+// and searched by addEvidence after redaction but before truncation, so offsets point
+// to the redacted bodies and matches are found even if sent bodies are cut before them.
+type evidenceFinder struct {
+	context int
+	vulns   map[*issue.HttpTransaction]*Vulnerability
+}
+
+func newEvidenceFinder(conf *evidenceConf) *evidenceFinder {
+	f := &evidenceFinder{
+		context: defaultEvidenceContext,
+		vulns:   map[*issue.HttpTransaction]*Vulnerability{},
+	}
+	if conf != nil && conf.Context > 0 {
+		f.context = conf.Context
+	}
+	return f
+}
+
+// Process implements findingProcessor
+func (f *evidenceFinder) Process(vuln *Vulnerability, issueObj *issue.Issue) bool {
+	if issueObj.Vector == nil {
+		return true
+	}
+	for _, trans := range issueObj.Vector.HttpTransactions {
+		f.vulns[trans] = vuln
+	}
+	return true
+}
+
+// addEvidence appends evidence of processed transactions to the issue description,
+// it should be called after redaction and before truncation of bodies.
+func (f *evidenceFinder) addEvidence(issueObj *issue.Issue) {
+	if issueObj.Vector == nil {
+		return
+	}
+	blocks := []string{}
+	for _, trans := range issueObj.Vector.HttpTransactions {
+		vuln, ok := f.vulns[trans]
+		if !ok {
+			continue
+		}
+		for _, ev := range f.find(vuln, trans) {
+			blocks = append(blocks, fmt.Sprintf("Transaction %d, bytes %d-%d of the response body before truncation:\n```\n%s\n```",
+				ev.TransactionId, ev.Start, ev.End, ev.Excerpt))
+		}
+	}
+	if len(blocks) > 0 {
+		issueObj.Desc += fmt.Sprintf("\n\n###Evidence:\n%s", strings.Join(blocks, "\n\n"))
+	}
+}
+
+func (f *evidenceFinder) find(vuln *Vulnerability, trans *issue.HttpTransaction) []*evidence {
+	if trans.Response == nil || trans.Response.Body == nil || trans.Response.Body.ContentEncoding != encodingText {
+		return nil
+	}
+	body := trans.Response.Body.Content
+	offsets := [][]int{}
+	if vuln.Var != "" && trans.Request != nil {
+		if value, ok := requestParamValue(trans.Request, vuln.Var); ok && len(value) >= minReflectedLen {
+			for start := 0; len(offsets) < maxEvidencePerBody; {
+				i := strings.Index(body[start:], value)
+				if i < 0 {
+					break
+				}
+				offsets = append(offsets, []int{start + i, start + i + len(value)})
+				start += i + len(value)
+			}
+		}
+	}
+	if len(offsets) == 0 && errorPlugins[vuln.Plugin] {
+		offsets = sqlErrorPattern.FindAllStringIndex(body, maxEvidencePerBody)
+	}
+	evidences := []*evidence{}
+	for _, offset := range offsets {
+		evidences = append(evidences, &evidence{
+			TransactionId: trans.Id,
+			Start:         offset[0],
+			End:           offset[1],
+			Excerpt:       excerpt(body, offset[0], offset[1], f.context),
+		})
+	}
+	return evidences
+}
+
+// excerpt returns body[start:end] with context around it, cut at rune boundaries
+func excerpt(body string, start, end, context int) string {
+	from := start - context
+	if from < 0 {
+		from = 0
+	}
+	to := end + context
+	if to > len(body) {
+		to = len(body)
+	}
+	for from > 0 && !utf8.RuneStart(body[from]) {
+		from--
+	}
+	for to < len(body) && !utf8.RuneStart(body[to]) {
+		to++
+	}
+	out := body[from:to]
+	if from > 0 {
+		out = "..." + out
+	}
+	if to < len(body) {
+		out += "..."
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package w3af
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExcerpt(t *testing.T) {
+	body := "0123456789PAYLOAD0123456789"
+	assert.Equal(t, "...89PAYLOAD01...", excerpt(body, 10, 17, 2))
+	assert.Equal(t, body, excerpt(body, 10, 17, 100))
+	// utf-8 is not broken
+	body = "ффPAYLOADфф"
+	assert.Equal(t, "...фPAYLOADф...", excerpt(body, 4, 11, 1))
+}
+
+func TestEvidenceFinder(t *testing.T) {
+	xmlReport, err := parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+
+	f := newEvidenceFinder(&evidenceConf{Context: 10})
+	issues, err := transformXmlReport(xmlReport, nil, 0, f)
+	require.NoError(t, err)
+	assert.NotContains(t, issues[2].Desc, "###Evidence:")
+	for _, issueObj := range issues {
+		f.addEvidence(issueObj)
+	}
+	desc := issues[2].Desc
+	assert.Contains(t, desc, "###Evidence:\nTransaction 89, bytes ")
+	assert.Contains(t, desc, `xbdwr"xbdwr`)
+
+	// sql errors
+	trans := newTestTransaction("GET /?id=1%27 HTTP/1.1", "HTTP/1.1 500 Internal Server Error",
+		strings.Repeat("a", 100)+"You have an error in your SQL syntax; check the manual")
+	trans.Id = 5
+	issueObj := &issue.Issue{Vector: &issue.Vector{HttpTransactions: []*issue.HttpTransaction{trans}}}
+	f = newEvidenceFinder(nil)
+	assert.True(t, f.Process(&Vulnerability{Plugin: "sqli", Var: "id"}, issueObj))
+	f.addEvidence(issueObj)
+	assert.Contains(t, issueObj.Desc, "###Evidence:\nTransaction 5, bytes 100-136 of the response body before truncation:\n```\n...")
+
+	// nothing is found
+	issueObj.Desc = ""
+	f = newEvidenceFinder(nil)
+	f.Process(&Vulnerability{Plugin: "xss", Var: "id"}, issueObj)
+	f.addEvidence(issueObj)
+	assert.Equal(t, "", issueObj.Desc)
+}
+
+func TestEvidenceAfterRedaction(t *testing.T) {
+	payload := "<script>alert(1)</script>"
+	trans := newTestTransaction("GET /?q=%3Cscript%3Ealert%281%29%3C%2Fscript%3E HTTP/1.1", "HTTP/1.1 200 OK",
+		`<a href="/?token=`+strings.Repeat("s", 100)+`">x</a>`+payload)
+	trans.Response.Header.Set("Set-Cookie", "session="+strings.Repeat("c", 100))
+	issueObj := &issue.Issue{Vector: &issue.Vector{HttpTransactions: []*issue.HttpTransaction{trans}}}
+	f := newEvidenceFinder(nil)
+	f.Process(&Vulnerability{Plugin: "xss", Var: "q"}, issueObj)
+
+	r, err := newRedactor(nil)
+	require.NoError(t, err)
+	r.RedactIssues([]*issue.Issue{issueObj})
+	f.addEvidence(issueObj)
+
+	body := trans.Response.Body.Content
+	start := strings.Index(body, payload)
+	require.True(t, start > 0)
+	assert.NotContains(t, body, strings.Repeat("s", 100))
+	// offsets point to the redacted body
+	assert.Contains(t, issueObj.Desc, fmt.Sprintf("bytes %d-%d of", start, start+len(payload)))
+}
+
+func TestEvidencePastTruncation(t *testing.T) {
+	payload := "<script>alert(1)</script>"
+	trans := newTestTransaction("GET /?q=%3Cscript%3Ealert%281%29%3C%2Fscript%3E HTTP/1.1", "HTTP/1.1 200 OK",
+		strings.Repeat("a", 1000)+payload)
+	issueObj := &issue.Issue{Vector: &issue.Vector{HttpTransactions: []*issue.HttpTransaction{trans}}}
+	f := newEvidenceFinder(&evidenceConf{Context: 10})
+	f.Process(&Vulnerability{Plugin: "xss", Var: "q"}, issueObj)
+
+	f.addEvidence(issueObj)
+	assert.Equal(t, 1, truncateIssues([]*issue.Issue{issueObj}, &chunkConf{MaxBody: 100}))
+	assert.NotContains(t, trans.Response.Body.Content, payload)
+	// offsets point to the body before truncation
+	assert.Contains(t, issueObj.Desc, "bytes 1000-1025 of the response body before truncation:\n```\n...aaaaaaaaaa"+payload+"\n```")
+}
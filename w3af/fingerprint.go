@@ -0,0 +1,35 @@
+package w3af
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
+
+// vulnFingerprint returns stable id of the vulnerability,
+// it doesn't depend on payloads, query values and descriptions which differ from scan to scan.
+func vulnFingerprint(vuln *Vulnerability) string {
+	location := vuln.Url
+	if u, err := url.Parse(vuln.Url); err == nil {
+		location = fmt.Sprintf("%s://%s%s", strings.ToLower(u.Scheme), strings.ToLower(u.Host), u.Path)
+	}
+	fields := []string{
+		vuln.Plugin,
+		vuln.Name,
+		strings.ToUpper(vuln.Method),
+		location,
+		vuln.Var,
+	}
+	hash := md5.New()
+	hash.Write([]byte(strings.Join(fields, ":")))
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// setFingerprint is a finding processor which keeps fingerprint in the issue UniqId
+func setFingerprint(vuln *Vulnerability, issueObj *issue.Issue) bool {
+	issueObj.UniqId = vulnFingerprint(vuln)
+	return true
+}
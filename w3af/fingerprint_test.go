@@ -0,0 +1,42 @@
+package w3af
+
+import (
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVulnFingerprint(t *testing.T) {
+	vuln := &Vulnerability{
+		Plugin: "xss",
+		Name:   "Cross site scripting vulnerability",
+		Method: "GET",
+		Url:    "http://example.com/xss?in=payload1",
+		Var:    "in",
+	}
+	fp := vulnFingerprint(vuln)
+	assert.Len(t, fp, 32)
+
+	// query, host case and description don't matter
+	other := *vuln
+	other.Url = "http://EXAMPLE.com/xss?in=payload2"
+	other.Description = "another description"
+	other.Id = "[100]"
+	assert.Equal(t, fp, vulnFingerprint(&other))
+
+	// but path, param and plugin do
+	other = *vuln
+	other.Url = "http://example.com/xss2"
+	assert.NotEqual(t, fp, vulnFingerprint(&other))
+	other = *vuln
+	other.Var = "out"
+	assert.NotEqual(t, fp, vulnFingerprint(&other))
+	other = *vuln
+	other.Plugin = "sqli"
+	assert.NotEqual(t, fp, vulnFingerprint(&other))
+
+	issueObj := &issue.Issue{}
+	assert.True(t, setFingerprint(vuln, issueObj))
+	assert.Equal(t, fp, issueObj.UniqId)
+}
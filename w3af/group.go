@@ -0,0 +1,206 @@
+package w3af
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
+
+var (
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexSegment  = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	numSegment  = regexp.MustCompile(`^\d+$`)
+)
+
+var severityOrder = map[issue.Severity]int{
+	issue.SeverityInfo:   1,
+	issue.SeverityLow:    2,
+	issue.SeverityMedium: 3,
+	issue.SeverityHigh:   4,
+	issue.SeverityError:  5,
+}
+
+type groupConf struct {
+	Enabled    bool `json:"enabled"`
+	IgnorePath bool `json:"ignorePath"` // group by plugin, name and parameter only
+}
+
+type groupMember struct {
+	Url         string
+	Var         string
+	Description string
+	Details     string // the rest of issue description like fix guidance
+}
+
+type cluster struct {
+	issue   *issue.Issue
+	members []*groupMember
+	plugin  string
+	param   string
+	scored  bool // members have confidence levels, the cluster level is computed in Finish
+}
+
+// grouper merges near-identical findings into one issue with many transactions
+type grouper struct {
+	ignorePath bool
+	clusters   map[string]*cluster
+	order      []string
+}
+
+func newGrouper(conf *groupConf) *grouper {
+	g := &grouper{
+		clusters: map[string]*cluster{},
+	}
+	if conf != nil {
+		g.ignorePath = conf.IgnorePath
+	}
+	return g
+}
+
+// Process implements findingProcessor, issues which join an existing cluster are dropped.
+// Confidence levels of members are removed, because the cluster has more evidence than any member.
+func (g *grouper) Process(vuln *Vulnerability, issueObj *issue.Issue) bool {
+	key := g.key(vuln)
+	scored := unmarkConfidence(issueObj) != ""
+	member := &groupMember{
+		Url:         vuln.Url,
+		Var:         vuln.Var,
+		Description: vuln.Description,
+		Details:     strings.TrimPrefix(issueObj.Desc, vuln.Description),
+	}
+	c, ok := g.clusters[key]
+	if !ok {
+		g.clusters[key] = &cluster{
+			issue:   issueObj,
+			members: []*groupMember{member},
+			plugin:  vuln.Plugin,
+			param:   vuln.Var,
+			scored:  scored,
+		}
+		g.order = append(g.order, key)
+		return true
+	}
+	c.members = append(c.members, member)
+	if severityOrder[issueObj.Severity] > severityOrder[c.issue.Severity] {
+		c.issue.Severity = issueObj.Severity
+	}
+	if issueObj.Vector != nil {
+		if c.issue.Vector == nil {
+			c.issue.Vector = &issue.Vector{Url: issueObj.Vector.Url}
+		}
+		c.issue.Vector.HttpTransactions = append(c.issue.Vector.HttpTransactions, issueObj.Vector.HttpTransactions...)
+	}
+	for _, ref := range issueObj.References {
+		if !hasReference(c.issue.References, ref) {
+			c.issue.References = append(c.issue.References, ref)
+		}
+	}
+	for _, extra := range issueObj.Extras {
+		if !hasExtra(c.issue.Extras, extra) {
+			c.issue.Extras = append(c.issue.Extras, extra)
+		}
+	}
+	return false
+}
+
+// Finish makes combined descriptions for clusters with many vulnerabilities,
+// sets cluster ids and confidence levels, it should be called after the transformation.
+func (g *grouper) Finish() {
+	for _, key := range g.order {
+		c := g.clusters[key]
+		if c.issue.UniqId != "" {
+			// the same id whatever number of members, otherwise diff sees new and fixed issues
+			hash := md5.Sum([]byte(key))
+			c.issue.UniqId = fmt.Sprintf("%x", hash)
+		}
+		g.describe(c)
+		if c.scored {
+			markConfidence(c.issue, findingConfidence(c.plugin, c.param, c.issue))
+		}
+	}
+}
+
+// describe lists members in the description of clusters with many vulnerabilities
+func (g *grouper) describe(c *cluster) {
+	if len(c.members) < 2 {
+		return
+	}
+	lines := []string{}
+	details := []string{}
+	for _, m := range c.members {
+		line := fmt.Sprintf("- %s", m.Url)
+		if m.Var != "" {
+			line += fmt.Sprintf(" (parameter %s)", m.Var)
+		}
+		if m.Description != "" {
+			line += fmt.Sprintf(": %s", m.Description)
+		}
+		lines = append(lines, line)
+		if !hasString(details, m.Details) {
+			details = append(details, m.Details)
+		}
+	}
+	c.issue.Summary = fmt.Sprintf("%s (%d urls)", c.issue.Summary, len(c.members))
+	c.issue.Desc = fmt.Sprintf("Found %d similar vulnerabilities:\n%s%s",
+		len(c.members), strings.Join(lines, "\n"), strings.Join(details, ""))
+}
+
+func (g *grouper) key(vuln *Vulnerability) string {
+	fields := []string{vuln.Plugin, vuln.Name, vuln.Var}
+	if !g.ignorePath {
+		fields = append(fields, pathTemplate(vuln.Url))
+	}
+	return strings.Join(fields, ":")
+}
+
+// pathTemplate replaces ids in the url path with placeholders and drops the query,
+// e.g. http://example.com/users/12/edit?a=1 -> http://example.com/users/{num}/edit
+func pathTemplate(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, s := range segments {
+		switch {
+		case numSegment.MatchString(s):
+			segments[i] = "{num}"
+		case uuidSegment.MatchString(s):
+			segments[i] = "{uuid}"
+		case hexSegment.MatchString(s):
+			segments[i] = "{hex}"
+		}
+	}
+	return fmt.Sprintf("%s://%s%s", strings.ToLower(u.Scheme), strings.ToLower(u.Host), strings.Join(segments, "/"))
+}
+
+func hasExtra(extras []*issue.Extra, extra *issue.Extra) bool {
+	for _, e := range extras {
+		if e.Url == extra.Url && e.Title == extra.Title {
+			return true
+		}
+	}
+	return false
+}
+
+func hasString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasReference(refs []*issue.Reference, ref *issue.Reference) bool {
+	for _, r := range refs {
+		if r.Url == ref.Url {
+			return true
+		}
+	}
+	return false
+}
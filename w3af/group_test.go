@@ -0,0 +1,112 @@
+package w3af
+
+import (
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathTemplate(t *testing.T) {
+	assert.Equal(t, "http://example.com/users/{num}/edit", pathTemplate("http://EXAMPLE.com/users/12/edit?a=1"))
+	assert.Equal(t, "https://example.com/files/{uuid}", pathTemplate("https://example.com/files/0b5e1c4a-8a43-4d35-9a57-2d0c4a6e36b1"))
+	assert.Equal(t, "http://example.com/obj/{hex}/", pathTemplate("http://example.com/obj/5530ed9b1e4a7a0d3c000001/"))
+	assert.Equal(t, "http://example.com/xss/reflect/basic", pathTemplate("http://example.com/xss/reflect/basic"))
+}
+
+func TestGrouper(t *testing.T) {
+	xmlReport, err := parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+	vulns := xmlReport.Vulnerabilities[:3]
+	for i, vuln := range vulns {
+		vuln.Url = []string{
+			"http://example.com/items/1?in=a",
+			"http://example.com/items/2?in=b",
+			"http://example.com/items/3/edit",
+		}[i]
+	}
+	vulns[1].Severity = SevHigh
+	vulns[1].FixGuidance = "Escape the output."
+	vulns[1].Var = "in"
+
+	g := newGrouper(&groupConf{Enabled: true})
+	issues, err := transformXmlReport(xmlReport, nil, 0, processorFunc(setFingerprint), g)
+	require.NoError(t, err)
+	g.Finish()
+	// 2 errors, 2 vulnerabilities are merged
+	require.Len(t, issues, 22)
+
+	grouped := issues[2]
+	assert.Equal(t, "Cross site scripting vulnerability (2 urls)", grouped.Summary)
+	assert.Equal(t, issue.SeverityHigh, grouped.Severity)
+	assert.Len(t, grouped.Vector.HttpTransactions,
+		len(vulns[0].HttpTransactions)+len(vulns[1].HttpTransactions))
+	assert.Contains(t, grouped.Desc, "Found 2 similar vulnerabilities:\n"+
+		"- http://example.com/items/1?in=a (parameter in): "+vulns[0].Description+"\n"+
+		"- http://example.com/items/2?in=b (parameter in): "+vulns[1].Description)
+	// fix guidance of both members
+	assert.Contains(t, grouped.Desc, "###Fix guidance:\n To remedy XSS")
+	assert.Contains(t, grouped.Desc, "###Fix guidance:\n Escape the output.")
+	assert.Equal(t, []*issue.Extra{injectedParamExtra("in")}, grouped.Extras)
+	assert.NotEqual(t, vulnFingerprint(vulns[0]), grouped.UniqId)
+
+	single := issues[3]
+	assert.Equal(t, "Cross site scripting vulnerability", single.Summary)
+	assert.NotEqual(t, vulnFingerprint(vulns[2]), single.UniqId)
+
+	// the cluster id doesn't depend on the number of members
+	xmlReport.Vulnerabilities = vulns[:1]
+	g = newGrouper(&groupConf{Enabled: true})
+	issues, err = transformXmlReport(xmlReport, nil, 0, processorFunc(setFingerprint), g)
+	require.NoError(t, err)
+	g.Finish()
+	require.Len(t, issues, 3)
+	assert.Equal(t, grouped.UniqId, issues[2].UniqId)
+
+	// group by plugin, name and parameter only
+	xmlReport, err = parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+	g = newGrouper(&groupConf{Enabled: true, IgnorePath: true})
+	issues, err = transformXmlReport(xmlReport, nil, 0, g)
+	require.NoError(t, err)
+	g.Finish()
+	assert.True(t, len(issues) < 10)
+	for _, issueObj := range issues[2:] {
+		assert.NotEqual(t, issueObj.Summary, "Cross site scripting vulnerability")
+	}
+}
+
+func TestGrouperConfidence(t *testing.T) {
+	scorer, err := newConfidenceScorer(nil)
+	require.NoError(t, err)
+	g := newGrouper(&groupConf{Enabled: true})
+	transactions := []*issue.HttpTransaction{
+		newTestTransaction("GET /items/1?in=%3Cscript%3E HTTP/1.1", "HTTP/1.1 200 OK", "<p><script></p>"),
+		newTestTransaction("GET /items/2?in=%3Cscript%3E HTTP/1.1", "HTTP/1.1 404 Not Found", ""),
+	}
+	levels := []string{}
+	for i, trans := range transactions {
+		vuln := &Vulnerability{Plugin: "xss", Name: "Cross site scripting vulnerability", Var: "in",
+			Url: []string{"http://example.com/items/1", "http://example.com/items/2"}[i]}
+		issueObj := &issue.Issue{
+			UniqId:  "id",
+			Summary: vuln.Name,
+			Vector:  &issue.Vector{HttpTransactions: []*issue.HttpTransaction{trans}},
+		}
+		require.True(t, scorer.Process(vuln, issueObj))
+		levels = append(levels, issueObj.Extras[0].Title)
+		if g.Process(vuln, issueObj) {
+			require.Equal(t, 0, i)
+		}
+	}
+	// members have different confidence levels
+	assert.Equal(t, []string{"Confidence: high", "Confidence: low"}, levels)
+
+	g.Finish()
+	grouped := g.clusters[g.order[0]].issue
+	// the level is computed once for the whole cluster
+	assert.Equal(t, "[high confidence] Cross site scripting vulnerability (2 urls)", grouped.Summary)
+	require.Len(t, grouped.Extras, 1)
+	assert.Equal(t, "Confidence: high", grouped.Extras[0].Title)
+}
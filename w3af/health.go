@@ -0,0 +1,110 @@
+package w3af
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
+
+const (
+	defaultMaxErrors = 10
+	// all error responses mean blocked scan only if there are enough of them
+	minHealthResponses = 5
+)
+
+type healthConf struct {
+	Disabled  bool `json:"disabled"`
+	MaxErrors int  `json:"maxErrors"` // number of <error> elements when the scan is ineffective, 10 by default
+}
+
+// checkHealth looks for signs that w3af scanned nothing: dns failures, waf blocks, login redirects and etc.
+// It returns the list of reasons or nil if the scan looks fine.
+func checkHealth(xmlRep *XmlReport, log *scanLog, target string, conf *healthConf) []string {
+	maxErrors := defaultMaxErrors
+	if conf != nil && conf.MaxErrors > 0 {
+		maxErrors = conf.MaxErrors
+	}
+	if xmlRep.ScanInfo != nil && xmlRep.ScanInfo.Target != "" {
+		target = xmlRep.ScanInfo.Target
+	}
+	reasons := []string{}
+	if log.urlsReported && log.Urls == 0 {
+		reasons = append(reasons, "w3af didn't find any url at the target")
+	}
+	if targetUrl, err := url.Parse(target); err == nil && targetUrl.Host != "" && len(log.UrlList) > 0 {
+		reachable := 0
+		for _, u := range log.UrlList {
+			if crawled, err := url.Parse(u); err == nil && sameHost(crawled, targetUrl) {
+				reachable++
+			}
+		}
+		if reachable == 0 {
+			reasons = append(reasons, fmt.Sprintf("none of %d crawled urls belongs to the target %s", len(log.UrlList), targetUrl.Host))
+		}
+	}
+	// w3af debug output has all responses, otherwise responses of findings are used,
+	// but the ones of error based plugins are expected to be errors
+	responses, errors := log.Responses, log.ErrorResponses
+	for _, vuln := range xmlRep.Vulnerabilities {
+		if log.Responses > 0 {
+			break
+		}
+		if errorPlugins[vuln.Plugin] {
+			continue
+		}
+		for _, trans := range vuln.HttpTransactions {
+			if trans.Response == nil {
+				continue
+			}
+			responses++
+			if status := responseStatus(&issue.HttpEntity{Status: trans.Response.Status}); status == 0 || status >= 400 {
+				errors++
+			}
+		}
+	}
+	if responses >= minHealthResponses && errors == responses {
+		reasons = append(reasons, fmt.Sprintf("all %d http responses were errors", responses))
+	}
+	if len(xmlRep.Errors) >= maxErrors {
+		reasons = append(reasons, fmt.Sprintf("w3af reported %d errors", len(xmlRep.Errors)))
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	return reasons
+}
+
+// sameHost compares hostnames and ports of urls, the scheme default port is used if the port isn't set,
+// so http://example.com:80/ and http://example.com/ are the same host
+func sameHost(a, b *url.URL) bool {
+	return strings.EqualFold(a.Hostname(), b.Hostname()) && effectivePort(a) == effectivePort(b)
+}
+
+func effectivePort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// ineffectiveIssue returns error issue explaining why the scan results can't be trusted
+func ineffectiveIssue(reasons []string) *issue.Issue {
+	lines := []string{}
+	for _, reason := range reasons {
+		lines = append(lines, "- "+reason)
+	}
+	return &issue.Issue{
+		Severity: issue.SeverityError,
+		Summary:  "W3af scan was ineffective",
+		Desc: "The scan most likely didn't test the target, so the absence of findings doesn't mean it is secure. " +
+			"Check the target url, dns, authentication and waf settings.\n\n" + strings.Join(lines, "\n"),
+	}
+}
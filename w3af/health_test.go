@@ -0,0 +1,127 @@
+package w3af
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestCheckHealth(t *testing.T) {
+	xmlReport, err := parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+	// the test report is fine
+	assert.Nil(t, checkHealth(xmlReport, parseLog(""), "", nil))
+	assert.Nil(t, checkHealth(&XmlReport{}, parseLog(string(loadTestData("console.log"))), "http://example.com", nil))
+
+	// too many errors
+	assert.Equal(t, []string{"w3af reported 2 errors"}, checkHealth(xmlReport, parseLog(""), "", &healthConf{MaxErrors: 2}))
+
+	// zero crawled urls
+	empty := &XmlReport{}
+	log := parseLog("Found 0 URLs and 0 different injections points.")
+	assert.Equal(t, []string{"w3af didn't find any url at the target"}, checkHealth(empty, log, "http://example.com", nil))
+
+	// redirected to another host
+	log = parseLog("Found 2 URLs and 1 different injections points.\nThe URL list is:\n" +
+		"- http://sso.example.org/login\n- http://sso.example.org/login?next=%2F\nScan finished in 3 seconds.")
+	assert.Equal(t, []string{"none of 2 crawled urls belongs to the target example.com"},
+		checkHealth(empty, log, "http://example.com/", nil))
+	empty.ScanInfo = &ScanInfo{Target: "http://sso.example.org/"}
+	assert.Nil(t, checkHealth(empty, log, "http://example.com/", nil))
+	empty.ScanInfo = nil
+
+	// default ports are the same host, other ports aren't
+	log = parseLog("Found 1 URLs and 1 different injections points.\nThe URL list is:\n" +
+		"- http://Example.com:80/login\nScan finished in 3 seconds.")
+	assert.Nil(t, checkHealth(empty, log, "http://example.com/", nil))
+	assert.Equal(t, []string{"none of 1 crawled urls belongs to the target example.com:8080"},
+		checkHealth(empty, log, "http://example.com:8080/", nil))
+	log = parseLog("Found 1 URLs and 1 different injections points.\nThe URL list is:\n" +
+		"- https://example.com/login\nScan finished in 3 seconds.")
+	assert.Nil(t, checkHealth(empty, log, "https://example.com:443/", nil))
+	assert.Equal(t, []string{"none of 1 crawled urls belongs to the target example.com"},
+		checkHealth(empty, log, "http://example.com/", nil))
+
+	// all responses are errors
+	blocked := &XmlReport{Vulnerabilities: []*Vulnerability{&Vulnerability{Plugin: "xss"}}}
+	for i := 0; i < minHealthResponses; i++ {
+		blocked.Vulnerabilities[0].HttpTransactions = append(blocked.Vulnerabilities[0].HttpTransactions,
+			&HttpTransaction{Response: &HttpEntity{Status: "HTTP/1.1 403 Forbidden"}})
+	}
+	assert.Equal(t, []string{"all 5 http responses were errors"}, checkHealth(blocked, parseLog(""), "", nil))
+	blocked.Vulnerabilities[0].HttpTransactions[1].Response.Status = "HTTP/1.1 200 OK"
+	assert.Nil(t, checkHealth(blocked, parseLog(""), "", nil))
+}
+
+func TestCheckHealthResponses(t *testing.T) {
+	// nothing is found, but the log shows that every request was blocked
+	lines := []string{"Found 3 URLs and 2 different injections points."}
+	for i := 0; i < 6; i++ {
+		lines = append(lines, fmt.Sprintf(`GET http://example.com/%d returned HTTP code "403" (id=%d,from_cache=0)`, i, i+1))
+	}
+	log := parseLog(strings.Join(lines, "\n"))
+	assert.Equal(t, 6, log.Responses)
+	assert.Equal(t, []string{"all 6 http responses were errors"}, checkHealth(&XmlReport{}, log, "", nil))
+	log = parseLog(strings.Join(append(lines, `GET http://example.com/ returned HTTP code "200" (id=7,from_cache=0)`), "\n"))
+	assert.Nil(t, checkHealth(&XmlReport{}, log, "", nil))
+
+	// sql injection is found by the server error
+	sqli := &XmlReport{Vulnerabilities: []*Vulnerability{&Vulnerability{Plugin: "sqli", HttpTransactions: []*HttpTransaction{
+		&HttpTransaction{Response: &HttpEntity{Status: "HTTP/1.1 500 Internal Server Error"}},
+	}}}}
+	assert.Nil(t, checkHealth(sqli, parseLog(""), "", nil))
+	// a few error responses aren't enough
+	sqli.Vulnerabilities[0].Plugin = "xss"
+	assert.Nil(t, checkHealth(sqli, parseLog(""), "", nil))
+}
+
+func TestIneffectiveIssue(t *testing.T) {
+	issueObj := ineffectiveIssue([]string{"one", "two"})
+	assert.Equal(t, issue.SeverityError, issueObj.Severity)
+	assert.True(t, strings.HasSuffix(issueObj.Desc, "\n\n- one\n- two"))
+}
+
+func TestW3afHandleScannedNothing(t *testing.T) {
+	api := newTestApi()
+	defer api.Close()
+	api.RunningPolls = 0
+	api.Items = nil
+	api.Logs = []*apiLogEntry{&apiLogEntry{Message: "Found 0 URLs and 0 different injections points."}}
+
+	formData, err := json.Marshal(map[string]interface{}{
+		"backend": BackendApi,
+		"api":     map[string]interface{}{"url": api.URL},
+	})
+	require.NoError(t, err)
+	conf := &plan.Conf{
+		Target:   "http://example.com",
+		FormData: string(formData),
+	}
+
+	bg := context.Background()
+	client := &ClientMock{}
+	client.On("SendReport", mock.Anything, mock.Anything).Return(nil).Once()
+
+	err = newTestW3af(t).Handle(bg, client, conf)
+	require.NoError(t, err)
+	client.Mock.AssertExpectations(t)
+
+	require.Len(t, client.Reports, 1)
+	sent := client.Reports[0]
+	// report is not empty
+	assert.Equal(t, report.TypeMulti, sent.Type)
+	summaries := []string{}
+	for _, issueObj := range sent.GetAllIssues() {
+		summaries = append(summaries, issueObj.Summary)
+	}
+	assert.Contains(t, summaries, "W3af scan was ineffective")
+}
@@ -0,0 +1,202 @@
+package w3af
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
+
+// w3af console messages, see w3af/core/controllers,
+// error patterns are anchored to w3af error messages, because info lines mention timeouts and stops too
+var (
+	logPluginException = regexp.MustCompile(`(?i)an exception was found while running ([\w.]+)`)
+	logTraceback       = regexp.MustCompile(`^Traceback \(most recent call last\):`)
+	logExceptionLine   = regexp.MustCompile(`^(\w+(?:\.\w+)*(?:Error|Exception)): (.*)$`)
+	logConnection      = regexp.MustCompile(`(?i)(too many consecutive errors|HTTP timeout error|request for \S+ timed out|` +
+		`connection refused|connection reset|name or service not known|no route to host|failed to resolve)`)
+	logAuth  = regexp.MustCompile(`(?i)(login failed|failed to log ?in|authentication failed|invalid credentials|not logged in)`)
+	logAbort = regexp.MustCompile(`(?i)(following error was detected by w3af and couldn't be resolved|` +
+		`ScanMustStop\w*Exc|w3af crashed|unhandled exception)`)
+	logDuration = regexp.MustCompile(`(?i)scan finished in (.+?)\.?$`)
+	logRequests = regexp.MustCompile(`(?i)(\d+) (?:HTTP )?requests? (?:were )?sent|sent (\d+) (?:HTTP )?requests`)
+	logUrls     = regexp.MustCompile(`(?i)found (\d+) urls? and (\d+) different injections? points?`)
+	logUrlList  = regexp.MustCompile(`(?i)^the url list is:`)
+	logUrlItem  = regexp.MustCompile(`^- (https?://\S+)`)
+	// debug output of every http request
+	logResponse = regexp.MustCompile(`returned HTTP code "(\d{3})"`)
+)
+
+// scanLog is structured diagnostics from w3af console output
+type scanLog struct {
+	Exceptions       map[string]int // message -> count
+	ConnectionErrors map[string]int
+	AuthFailures     map[string]int
+	AbortReasons     map[string]int
+
+	Requests int
+	Urls     int
+	UrlList  []string // crawled urls
+	Duration string
+
+	// http responses from debug output
+	Responses      int
+	ErrorResponses int // with 4xx and 5xx status
+
+	urlsReported bool // w3af printed the number of found urls
+}
+
+// Errors returns total number of errors found in the log
+func (l *scanLog) Errors() int {
+	total := 0
+	for _, m := range []map[string]int{l.Exceptions, l.ConnectionErrors, l.AuthFailures, l.AbortReasons} {
+		for _, count := range m {
+			total += count
+		}
+	}
+	return total
+}
+
+func parseLog(text string) *scanLog {
+	l := &scanLog{
+		Exceptions:       map[string]int{},
+		ConnectionErrors: map[string]int{},
+		AuthFailures:     map[string]int{},
+		AbortReasons:     map[string]int{},
+	}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	inTraceback, inUrlList := false, false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if inUrlList {
+			if m := logUrlItem.FindStringSubmatch(line); m != nil {
+				l.UrlList = append(l.UrlList, m[1])
+				continue
+			}
+			inUrlList = false
+		}
+		if logUrlList.MatchString(line) {
+			inUrlList = true
+			continue
+		}
+		if logTraceback.MatchString(line) {
+			inTraceback = true
+			continue
+		}
+		if inTraceback {
+			// the last line of the traceback is the exception itself
+			if m := logExceptionLine.FindStringSubmatch(line); m != nil {
+				l.Exceptions[line]++
+				inTraceback = false
+			}
+			continue
+		}
+		// urls in request lines could look like errors
+		if m := logResponse.FindStringSubmatch(line); m != nil {
+			l.Responses++
+			if code, _ := strconv.Atoi(m[1]); code >= 400 {
+				l.ErrorResponses++
+			}
+			continue
+		}
+		switch {
+		case logPluginException.MatchString(line):
+			l.Exceptions[line]++
+		case logAuth.MatchString(line):
+			l.AuthFailures[line]++
+		case logAbort.MatchString(line):
+			l.AbortReasons[line]++
+		case logConnection.MatchString(line):
+			l.ConnectionErrors[line]++
+		}
+		if m := logDuration.FindStringSubmatch(line); m != nil {
+			l.Duration = m[1]
+		}
+		if m := logRequests.FindStringSubmatch(line); m != nil {
+			for _, n := range m[1:] {
+				if requests, err := strconv.Atoi(n); err == nil {
+					l.Requests = requests
+				}
+			}
+		}
+		if m := logUrls.FindStringSubmatch(line); m != nil {
+			l.Urls, _ = strconv.Atoi(m[1])
+			l.urlsReported = true
+		}
+	}
+	return l
+}
+
+// Abnormal is true if the log has errors or w3af didn't find any url
+func (l *scanLog) Abnormal() bool {
+	return l.Errors() > 0 || l.urlsReported && l.Urls == 0
+}
+
+// Issues converts diagnostics to error issues and adds the scan health summary,
+// the summary is an error for abnormal scans and info otherwise
+func (l *scanLog) Issues() []*issue.Issue {
+	issues := []*issue.Issue{}
+	groups := []struct {
+		summary  string
+		messages map[string]int
+	}{
+		{"Exception in w3af plugin", l.Exceptions},
+		{"W3af HTTP connection errors", l.ConnectionErrors},
+		{"W3af authentication failure", l.AuthFailures},
+		{"W3af scan was aborted", l.AbortReasons},
+	}
+	for _, g := range groups {
+		if len(g.messages) == 0 {
+			continue
+		}
+		issues = append(issues, &issue.Issue{
+			Severity: issue.SeverityError,
+			Summary:  g.summary,
+			Desc:     formatCounts(g.messages),
+		})
+	}
+	health := &issue.Issue{
+		Severity: issue.SeverityInfo,
+		Summary:  "W3af scan health",
+		Desc:     l.Health(),
+	}
+	if l.Abnormal() {
+		health.Severity = issue.SeverityError
+	}
+	return append(issues, health)
+}
+
+// Health returns compact scan health summary
+func (l *scanLog) Health() string {
+	lines := []string{
+		fmt.Sprintf("Requests: %d", l.Requests),
+		fmt.Sprintf("Urls: %d", l.Urls),
+		fmt.Sprintf("Errors: %d", l.Errors()),
+	}
+	if l.Duration != "" {
+		lines = append(lines, fmt.Sprintf("Duration: %s", l.Duration))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatCounts returns sorted list of messages with counts
+func formatCounts(messages map[string]int) string {
+	lines := []string{}
+	for msg, count := range messages {
+		line := fmt.Sprintf("- %s", msg)
+		if count > 1 {
+			line += fmt.Sprintf(" (%d times)", count)
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,68 @@
+package w3af
+
+import (
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLog(t *testing.T) {
+	l := parseLog(string(loadTestData("console.log")))
+	assert.Equal(t, 12, l.Urls)
+	assert.Equal(t, 1250, l.Requests)
+	assert.Equal(t, "2 minutes 31 seconds", l.Duration)
+	require.Len(t, l.Exceptions, 2)
+	assert.Equal(t, 1, l.Exceptions["KeyError: 'id'"])
+	assert.Len(t, l.ConnectionErrors, 1)
+	assert.Len(t, l.AuthFailures, 1)
+	assert.Len(t, l.AbortReasons, 0)
+	assert.Equal(t, 5, l.Errors())
+
+	issues := l.Issues()
+	require.Len(t, issues, 4)
+	assert.Equal(t, "Exception in w3af plugin", issues[0].Summary)
+	assert.Equal(t, issue.SeverityError, issues[0].Severity)
+	assert.Contains(t, issues[0].Desc, "running audit.sqli")
+	assert.Contains(t, issues[0].Desc, "(2 times)")
+	assert.Equal(t, "W3af authentication failure", issues[2].Summary)
+	assert.Equal(t, "W3af scan health", issues[3].Summary)
+	assert.Equal(t, issue.SeverityError, issues[3].Severity)
+	assert.Equal(t, "Requests: 1250\nUrls: 12\nErrors: 5\nDuration: 2 minutes 31 seconds", issues[3].Desc)
+}
+
+func TestParseLogEmpty(t *testing.T) {
+	issues := parseLog("").Issues()
+	require.Len(t, issues, 1)
+	assert.Equal(t, "W3af scan health", issues[0].Summary)
+}
+
+func TestParseLogOrdinary(t *testing.T) {
+	l := parseLog("Updating socket timeout for example.com from 6 to 10 seconds\n" +
+		"The user stopped the scan, scan stopped after 10 minutes.\n" +
+		"Stopping the scan, the max time limit was reached.\n" +
+		"Found 3 URLs and 5 different injections points.\n" +
+		"Scan finished in 10 minutes.")
+	assert.Equal(t, 0, l.Errors())
+	assert.False(t, l.Abnormal())
+	issues := l.Issues()
+	require.Len(t, issues, 1)
+	assert.Equal(t, issue.SeverityInfo, issues[0].Severity)
+	assert.Equal(t, "Requests: 0\nUrls: 3\nErrors: 0\nDuration: 10 minutes", issues[0].Desc)
+
+	// real w3af errors
+	l = parseLog("**IMPORTANT** The following error was detected by w3af and couldn't be resolved:\n" +
+		"w3af found too many consecutive errors while performing HTTP requests.\n" +
+		"HTTP timeout error for http://example.com/")
+	assert.Len(t, l.AbortReasons, 1)
+	assert.Len(t, l.ConnectionErrors, 2)
+
+	// nothing is found
+	l = parseLog("Found 0 URLs and 0 different injections points.")
+	assert.True(t, l.Abnormal())
+	issues = l.Issues()
+	require.Len(t, issues, 1)
+	assert.Equal(t, "W3af scan health", issues[0].Summary)
+	assert.Equal(t, issue.SeverityError, issues[0].Severity)
+}
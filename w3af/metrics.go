@@ -0,0 +1,62 @@
+package w3af
+
+import (
+	"github.com/bearded-web/w3af-script/metrics"
+)
+
+// reasons of dropped findings
+const (
+	dropUnknownSeverity = "unknown_severity"
+	dropSuppressed      = "suppressed"
+	dropBaseline        = "baseline"
+	dropLowConfidence   = "low_confidence"
+)
+
+var (
+	scansStarted  = metrics.NewCounter("w3af_script_scans_started_total", "Scans started.")
+	scansFinished = metrics.NewCounter("w3af_script_scans_finished_total", "Scans finished with the report sent.")
+	scansFailed   = metrics.NewCounter("w3af_script_scans_failed_total", "Scans failed.")
+
+	runDuration = metrics.NewHistogram("w3af_script_run_duration_seconds",
+		"Duration of w3af run by backend.", metrics.ScanBuckets, "backend")
+	reportXmlSize = metrics.NewHistogram("w3af_script_report_xml_bytes",
+		"Size of w3af report.xml.", metrics.SizeBuckets)
+	parseDuration = metrics.NewHistogram("w3af_script_report_xml_parse_duration_seconds",
+		"Duration of report.xml parsing.", metrics.DefaultBuckets)
+
+	findingIssues = metrics.NewCounter("w3af_script_issues_total",
+		"Issues made from w3af findings by severity and plugin.", "severity", "plugin")
+	findingsDropped = metrics.NewCounter("w3af_script_findings_dropped_total",
+		"W3af findings which aren't reported by reason.", "reason")
+	unknownSeverity = metrics.NewCounter("w3af_script_findings_unknown_severity_total",
+		"W3af findings with unknown severity by unknown severity policy.", "policy")
+)
+
+// findingMetrics counts findings in filters, findings which aren't of the current scan,
+// e.g. of the previous report, are counted with noopMetrics
+type findingMetrics interface {
+	Issue(severity, plugin string)
+	Dropped(reason string)
+	UnknownSeverity(policy string)
+}
+
+// scanMetrics counts findings of the current scan in the global metrics
+type scanMetrics struct{}
+
+func (scanMetrics) Issue(severity, plugin string) {
+	findingIssues.Inc(severity, plugin)
+}
+
+func (scanMetrics) Dropped(reason string) {
+	findingsDropped.Inc(reason)
+}
+
+func (scanMetrics) UnknownSeverity(policy string) {
+	unknownSeverity.Inc(policy)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) Issue(severity, plugin string) {}
+func (noopMetrics) Dropped(reason string)         {}
+func (noopMetrics) UnknownSeverity(policy string) {}
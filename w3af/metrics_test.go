@@ -0,0 +1,96 @@
+package w3af
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+
+	"github.com/bearded-web/w3af-script/metrics"
+)
+
+func TestW3afHandleMetrics(t *testing.T) {
+	conf := &plan.Conf{
+		Target:   "http://192.168.1.35:8082/",
+		FormData: `{"type": "plan", "data": "[audit]\nxss", "confidence": {"disabled": true}}`,
+	}
+	s := newTestW3af(t)
+	s.retry = testRetryPolicy
+
+	started, finished, failed := scansStarted.Value(), scansFinished.Value(), scansFailed.Value()
+	runs, parses := runDuration.Count(BackendUtil), parseDuration.Count()
+	xss := findingIssues.Value(string(issue.SeverityMedium), "xss")
+
+	require.NoError(t, s.Handle(context.Background(), &flakyClient{}, conf))
+	require.Error(t, s.Handle(context.Background(), &flakyClient{ReportFailures: 3}, conf))
+
+	assert.Equal(t, started+2, scansStarted.Value())
+	assert.Equal(t, finished+1, scansFinished.Value())
+	assert.Equal(t, failed+1, scansFailed.Value())
+	assert.Equal(t, runs+2, runDuration.Count(BackendUtil))
+	assert.Equal(t, parses+2, parseDuration.Count())
+	assert.True(t, findingIssues.Value(string(issue.SeverityMedium), "xss") > xss)
+
+	srv := httptest.NewServer(metrics.Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "# TYPE w3af_script_scans_started_total counter\n")
+	assert.Contains(t, string(body), `w3af_script_issues_total{severity="medium",plugin="xss"} `)
+	assert.Contains(t, string(body), `w3af_script_run_duration_seconds_count{backend="util"} `)
+	assert.Contains(t, string(body), "w3af_script_report_xml_bytes_bucket{le=\"+Inf\"} ")
+}
+
+func TestDroppedFindingsMetrics(t *testing.T) {
+	m, err := newSeverityMapper(nil)
+	require.NoError(t, err)
+	unknown, dropped := unknownSeverity.Value(UnknownDrop), findingsDropped.Value(dropUnknownSeverity)
+	_, ok := m.Severity(&Vulnerability{Severity: "Critical"})
+	assert.False(t, ok)
+	assert.Equal(t, unknown+1, unknownSeverity.Value(UnknownDrop))
+	assert.Equal(t, dropped+1, findingsDropped.Value(dropUnknownSeverity))
+
+	m, err = newSeverityMapper(&severityConf{Unknown: UnknownInfo})
+	require.NoError(t, err)
+	unknown, dropped = unknownSeverity.Value(UnknownInfo), findingsDropped.Value(dropUnknownSeverity)
+	_, ok = m.Severity(&Vulnerability{Severity: "Critical"})
+	assert.True(t, ok)
+	assert.Equal(t, unknown+1, unknownSeverity.Value(UnknownInfo))
+	assert.Equal(t, dropped, findingsDropped.Value(dropUnknownSeverity))
+}
+
+func TestPreviousFindingsMetrics(t *testing.T) {
+	bg := context.Background()
+	client := &ClientMock{}
+	client.On("DownloadFile", bg, "prev").Return(loadTestData("report.xml"), nil).Once()
+	filters, err := getScanFilters(bg, client, &w3afData{
+		Suppress:   &suppressConf{Rules: []*suppressRule{&suppressRule{Plugin: "xss", Name: "Cross site scripting vulnerability", Reason: "test"}}},
+		Confidence: &confidenceConf{Min: "high"},
+	})
+	require.NoError(t, err)
+
+	suppressed, lowConfidence := findingsDropped.Value(dropSuppressed), findingsDropped.Value(dropLowConfidence)
+	issues, err := getPreviousIssues(bg, client, &diffConf{PreviousReport: "prev"}, nil, filters)
+	require.NoError(t, err)
+	assert.True(t, len(issues) < 23)
+	assert.Equal(t, suppressed, findingsDropped.Value(dropSuppressed))
+	assert.Equal(t, lowConfidence, findingsDropped.Value(dropLowConfidence))
+
+	xmlData := loadTestData("report.xml")
+	baseline, err := GenerateBaseline(xmlData, "legacy")
+	require.NoError(t, err)
+	accepted := findingsDropped.Value(dropBaseline)
+	issues, err = CheckBaseline(xmlData, baseline)
+	require.NoError(t, err)
+	assert.Len(t, issues, 0)
+	assert.Equal(t, accepted, findingsDropped.Value(dropBaseline))
+}
@@ -51,7 +51,25 @@ type Error struct {
 	Desc   string `xml:",chardata"`
 }
 
+type ScanPlugin struct {
+	Name string `xml:"name,attr"`
+}
+
+// PluginType is a group of plugins like audit or crawl
+type PluginType struct {
+	XMLName xml.Name
+	Plugins []*ScanPlugin `xml:"plugin"`
+}
+
+type ScanInfo struct {
+	Target string        `xml:"target,attr"`
+	Types  []*PluginType `xml:",any"`
+}
+
 type XmlReport struct {
+	Start           string           `xml:"start,attr"` // unix time
+	W3afVersion     string           `xml:"w3af-version"`
+	ScanInfo        *ScanInfo        `xml:"scan-info"`
 	Vulnerabilities []*Vulnerability `xml:"vulnerability"`
 	Errors          []*Error         `xml:"error"`
 }
@@ -0,0 +1,131 @@
+package w3af
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
+
+// headers which are stripped from PoC unless they are explicitly allowed
+var pocSecretHeaders = []string{"Cookie", "Authorization"}
+
+// headers which are calculated by curl itself
+var pocSkipHeaders = []string{"Content-Length"}
+
+type pocConf struct {
+	Disabled     bool     `json:"disabled"`
+	AllowHeaders []string `json:"allowHeaders"` // secret headers allowed to be kept in PoC
+}
+
+// addReproduce appends a "Reproduce" section with curl command and raw http request
+// for every http transaction of the issue
+func addReproduce(issues []*issue.Issue, conf *pocConf) {
+	if conf == nil {
+		conf = &pocConf{}
+	}
+	if conf.Disabled {
+		return
+	}
+	for _, issueObj := range issues {
+		if issueObj.Vector == nil || len(issueObj.Vector.HttpTransactions) == 0 {
+			continue
+		}
+		blocks := []string{}
+		for _, trans := range issueObj.Vector.HttpTransactions {
+			if trans.Request == nil {
+				continue
+			}
+			curl, raw, stripped := genPoc(trans, conf.AllowHeaders)
+			block := fmt.Sprintf("Transaction %d:\n```\n%s\n```\n\n```\n%s\n```", trans.Id, curl, raw)
+			if len(stripped) > 0 {
+				block += fmt.Sprintf("\n\nStripped headers: %s", strings.Join(stripped, ", "))
+			}
+			blocks = append(blocks, block)
+		}
+		if len(blocks) > 0 {
+			issueObj.Desc += fmt.Sprintf("\n\n###Reproduce:\n%s", strings.Join(blocks, "\n\n"))
+		}
+	}
+}
+
+// genPoc returns curl command, raw http request and list of stripped headers
+func genPoc(trans *issue.HttpTransaction, allowHeaders []string) (string, string, []string) {
+	req := trans.Request
+	method, requestUri, proto, ok := parseRequestLine(req.Status)
+	if !ok {
+		method, requestUri, proto = trans.Method, trans.Url, "HTTP/1.1"
+	}
+	if method == "" {
+		method = "GET"
+	}
+	absUrl := requestUrl(req, trans.Url)
+	if absUrl == "" {
+		absUrl = trans.Url
+	}
+
+	stripped := []string{}
+	keys := []string{}
+	for key := range req.Header {
+		canonical := http.CanonicalHeaderKey(key)
+		if containsHeader(pocSkipHeaders, canonical) {
+			continue
+		}
+		if containsHeader(pocSecretHeaders, canonical) && !containsHeader(allowHeaders, canonical) {
+			stripped = append(stripped, canonical)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sort.Strings(stripped)
+
+	curl := []string{"curl", "-i", "-s", "-k", "-X", shellEscape(method)}
+	raw := []string{fmt.Sprintf("%s %s %s", method, requestUri, proto)}
+	for _, key := range keys {
+		for _, value := range req.Header[key] {
+			header := fmt.Sprintf("%s: %s", http.CanonicalHeaderKey(key), value)
+			curl = append(curl, "-H", shellEscape(header))
+			raw = append(raw, header)
+		}
+	}
+	raw = append(raw, "")
+	curl = append(curl, shellEscape(absUrl))
+
+	curlCmd := strings.Join(curl, " ")
+	body := ""
+	if req.Body != nil && len(req.Body.Content) > 0 {
+		body = req.Body.Content
+		if req.Body.ContentEncoding == encodingBase64 {
+			curlCmd = fmt.Sprintf("echo %s | base64 -d | %s --data-binary @-",
+				shellEscape(req.Body.Content), curlCmd)
+			// raw request should have the bytes which are sent, not their base64
+			if decoded, err := base64.StdEncoding.DecodeString(stripSpaces(req.Body.Content)); err == nil {
+				body = string(decoded)
+			} else {
+				body = fmt.Sprintf("[base64 encoded body]\r\n%s", req.Body.Content)
+			}
+		} else {
+			curlCmd += " --data-binary " + shellEscape(req.Body.Content)
+		}
+	}
+	raw = append(raw, body)
+	return curlCmd, strings.Join(raw, "\r\n"), stripped
+}
+
+func containsHeader(headers []string, header string) bool {
+	for _, h := range headers {
+		if http.CanonicalHeaderKey(h) == header {
+			return true
+		}
+	}
+	return false
+}
+
+// shellEscape quotes string to be safely used as a single posix shell argument
+func shellEscape(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
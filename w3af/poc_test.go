@@ -0,0 +1,71 @@
+package w3af
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenPoc(t *testing.T) {
+	trans := &issue.HttpTransaction{
+		Id:     1,
+		Method: "POST",
+		Url:    "/login",
+		Request: &issue.HttpEntity{
+			Status: "POST /login HTTP/1.1",
+			Header: http.Header{
+				"Host":           []string{"example.com"},
+				"Cookie":         []string{"session=secret"},
+				"Authorization":  []string{"Basic c2VjcmV0"},
+				"Content-Length": []string{"12"},
+				"User-Agent":     []string{"w3af's agent"},
+			},
+			Body: &issue.HttpBody{ContentEncoding: "text", Content: "name=it's me"},
+		},
+	}
+	curl, raw, stripped := genPoc(trans, nil)
+	assert.Equal(t, []string{"Authorization", "Cookie"}, stripped)
+	assert.Equal(t, `curl -i -s -k -X 'POST' -H 'Host: example.com' -H 'User-Agent: w3af'"'"'s agent' `+
+		`'http://example.com/login' --data-binary 'name=it'"'"'s me'`, curl)
+	assert.Equal(t, "POST /login HTTP/1.1\r\nHost: example.com\r\nUser-Agent: w3af's agent\r\n\r\nname=it's me", raw)
+
+	// allowed secret headers
+	curl, raw, stripped = genPoc(trans, []string{"cookie"})
+	assert.Equal(t, []string{"Authorization"}, stripped)
+	assert.Contains(t, curl, `-H 'Cookie: session=secret'`)
+	assert.Contains(t, raw, "Cookie: session=secret\r\n")
+
+	// binary body
+	trans.Request.Body = &issue.HttpBody{ContentEncoding: "base64", Content: "AAEC"}
+	curl, raw, _ = genPoc(trans, nil)
+	assert.Contains(t, curl, "echo 'AAEC' | base64 -d | curl ")
+	assert.Contains(t, curl, "--data-binary @-")
+	// raw request has the decoded payload
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\n\x00\x01\x02"), "raw request: %q", raw)
+
+	// broken base64 is kept with a note
+	trans.Request.Body.Content = "AA!C"
+	_, raw, _ = genPoc(trans, nil)
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\n[base64 encoded body]\r\nAA!C"), "raw request: %q", raw)
+}
+
+func TestAddReproduce(t *testing.T) {
+	xmlReport, err := parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+	issues, err := transformXmlReport(xmlReport, nil, 0)
+	require.NoError(t, err)
+
+	addReproduce(issues, &pocConf{Disabled: true})
+	assert.NotContains(t, issues[2].Desc, "###Reproduce:")
+
+	addReproduce(issues, nil)
+	// errors don't have transactions
+	assert.NotContains(t, issues[0].Desc, "###Reproduce:")
+	assert.Contains(t, issues[2].Desc, "###Reproduce:\nTransaction 89:\n")
+	assert.Contains(t, issues[2].Desc,
+		"'http://192.168.1.35:8082/xss/reflect/js4_dq?in=xbdwr%22xbdwr'")
+}
@@ -0,0 +1,167 @@
+package w3af
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
+
+// headers with secret values which are always redacted
+var redactHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Csrf-Token",
+	"X-Xsrf-Token",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+// patterns for secrets in urls and bodies,
+// if the pattern has a group then only the group is redacted
+var redactPatterns = []string{
+	// jwt
+	`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`,
+	// bearer tokens
+	`(?i)bearer\s+([A-Za-z0-9\-._~+/]+=*)`,
+	// password and token parameters in query and form bodies
+	`(?i)(?:password|passwd|pwd|pass|secret|token|api_?key|access_token|csrf_?token|csrfmiddlewaretoken)=([^&\s"'<>]+)`,
+	// password and token fields in json bodies
+	`(?i)"(?:password|passwd|pwd|pass|secret|token|api_?key|access_token|csrf_?token)"\s*:\s*"([^"]+)"`,
+}
+
+type redactConf struct {
+	Disabled        bool     `json:"disabled"`
+	Headers         []string `json:"headers"`         // additional headers to redact
+	Patterns        []string `json:"patterns"`        // additional regexps to redact
+	MaxResponseBody int      `json:"maxResponseBody"` // drop response bodies larger than this size in bytes, 0 - no limit
+}
+
+// redactor replaces secrets in issues with placeholders,
+// the same secret is always replaced with the same placeholder
+type redactor struct {
+	disabled        bool
+	headers         []string
+	patterns        []*regexp.Regexp
+	maxResponseBody int
+
+	placeholders map[string]string // secret -> placeholder
+	secrets      map[string]string // placeholder -> secret
+
+	// number of dropped response bodies
+	DroppedBodies int
+}
+
+func newRedactor(conf *redactConf) (*redactor, error) {
+	if conf == nil {
+		conf = &redactConf{}
+	}
+	r := &redactor{
+		disabled:        conf.Disabled,
+		maxResponseBody: conf.MaxResponseBody,
+		placeholders:    map[string]string{},
+		secrets:         map[string]string{},
+	}
+	for _, h := range append(redactHeaders, conf.Headers...) {
+		r.headers = append(r.headers, http.CanonicalHeaderKey(h))
+	}
+	for _, p := range append(redactPatterns, conf.Patterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad redact pattern %q: %s", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+func (r *redactor) RedactIssues(issues []*issue.Issue) {
+	if r.disabled {
+		return
+	}
+	for _, issueObj := range issues {
+		// summaries contain urls, e.g. ones of fixed findings from the previous report
+		issueObj.Summary = r.redactString(issueObj.Summary)
+		issueObj.Desc = r.redactString(issueObj.Desc)
+		// extras are free-form plugin data
+		for _, extra := range issueObj.Extras {
+			extra.Url = r.redactString(extra.Url)
+			extra.Title = r.redactString(extra.Title)
+		}
+		if issueObj.Vector == nil {
+			continue
+		}
+		issueObj.Vector.Url = r.redactString(issueObj.Vector.Url)
+		for _, trans := range issueObj.Vector.HttpTransactions {
+			trans.Url = r.redactString(trans.Url)
+			r.redactEntity(trans.Request)
+			r.redactEntity(trans.Response)
+			if r.maxResponseBody > 0 && trans.Response != nil && trans.Response.Body != nil {
+				if size := len(trans.Response.Body.Content); size > r.maxResponseBody {
+					trans.Response.Body = &issue.HttpBody{
+						ContentEncoding: "text",
+						Content: fmt.Sprintf("Response body (%d bytes) is dropped, it exceeds %d bytes limit",
+							size, r.maxResponseBody),
+					}
+					r.DroppedBodies++
+				}
+			}
+		}
+	}
+}
+
+func (r *redactor) redactEntity(ent *issue.HttpEntity) {
+	if ent == nil {
+		return
+	}
+	ent.Status = r.redactString(ent.Status)
+	for key, values := range ent.Header {
+		if containsHeader(r.headers, http.CanonicalHeaderKey(key)) {
+			for i, value := range values {
+				values[i] = r.placeholder(value)
+			}
+			continue
+		}
+		for i, value := range values {
+			values[i] = r.redactString(value)
+		}
+	}
+	// base64 bodies are binary, there is nothing to match
+	if ent.Body != nil && ent.Body.ContentEncoding != "base64" {
+		ent.Body.Content = r.redactString(ent.Body.Content)
+	}
+}
+
+func (r *redactor) redactString(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			if re.NumSubexp() == 0 {
+				return r.placeholder(match)
+			}
+			sub := re.FindStringSubmatchIndex(match)
+			if sub == nil || sub[2] < 0 {
+				return match
+			}
+			return match[:sub[2]] + r.placeholder(match[sub[2]:sub[3]]) + match[sub[3]:]
+		})
+	}
+	return s
+}
+
+// placeholder returns the same placeholder for the same secret
+func (r *redactor) placeholder(secret string) string {
+	if p, ok := r.placeholders[secret]; ok {
+		return p
+	}
+	// already redacted
+	if _, ok := r.secrets[secret]; ok {
+		return secret
+	}
+	p := fmt.Sprintf("[REDACTED-%d]", len(r.placeholders)+1)
+	r.placeholders[secret] = p
+	r.secrets[p] = secret
+	return p
+}
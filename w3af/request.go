@@ -0,0 +1,204 @@
+package w3af
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
+
+// parseRequestLine parses "GET /foo HTTP/1.1" into its three parts.
+func parseRequestLine(line string) (method, requestURI, proto string, ok bool) {
+	s1 := strings.Index(line, " ")
+	if s1 < 0 {
+		return
+	}
+	s2 := strings.Index(line[s1+1:], " ")
+	if s2 < 0 {
+		return
+	}
+	s2 += s1 + 1
+	return line[:s1], line[s1+1 : s2], line[s2+1:], true
+}
+
+// requestUrl returns absolute url of the request,
+// relative urls are resolved with Host header and scheme from the vulnerability url.
+func requestUrl(req *issue.HttpEntity, vulnUrl string) string {
+	_, requestUri, _, ok := parseRequestLine(req.Status)
+	if !ok {
+		return ""
+	}
+	u, err := url.Parse(requestUri)
+	if err != nil || u.IsAbs() {
+		return requestUri
+	}
+	base, err := url.Parse(vulnUrl)
+	if err != nil {
+		base = &url.URL{}
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		base.Host = host
+	}
+	if base.Host == "" {
+		return requestUri
+	}
+	if base.Scheme == "" {
+		base.Scheme = "http"
+	}
+	return base.ResolveReference(u).String()
+}
+
+// requestParams returns names of all parameters sent in the query and the body,
+// the injected parameter is appended if the request doesn't contain it (e.g. a header).
+// Position doesn't mark the injected parameter, see injectedParamExtra.
+func requestParams(req *issue.HttpEntity, injected string) []string {
+	params := []string{}
+	seen := map[string]bool{}
+	add := func(names ...string) {
+		for _, name := range names {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				params = append(params, name)
+			}
+		}
+	}
+	if _, requestUri, _, ok := parseRequestLine(req.Status); ok {
+		if u, err := url.Parse(requestUri); err == nil {
+			add(queryParams(u.RawQuery)...)
+		}
+	}
+	if req.Body != nil && len(req.Body.Content) > 0 {
+		body := []byte(req.Body.Content)
+		if req.Body.ContentEncoding == encodingBase64 {
+			decoded, err := base64.StdEncoding.DecodeString(req.Body.Content)
+			if err != nil {
+				add(injected)
+				return params
+			}
+			body = decoded
+		}
+		add(bodyParams(body, req.Header.Get("Content-Type"))...)
+	}
+	add(injected)
+	return params
+}
+
+// injectedParamExtra marks the parameter which w3af injected into
+func injectedParamExtra(name string) *issue.Extra {
+	return &issue.Extra{Title: fmt.Sprintf("Injected parameter: %s", name)}
+}
+
+// requestParamValue returns value of the parameter from the query or the form body
+func requestParamValue(req *issue.HttpEntity, name string) (string, bool) {
+	if _, requestUri, _, ok := parseRequestLine(req.Status); ok {
+		if u, err := url.Parse(requestUri); err == nil {
+			if values, err := url.ParseQuery(u.RawQuery); err == nil {
+				if _, ok := values[name]; ok {
+					return values.Get(name), true
+				}
+			}
+		}
+	}
+	if req.Body != nil && req.Body.ContentEncoding == encodingText {
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if mediaType == "application/x-www-form-urlencoded" || mediaType == "" {
+			if values, err := url.ParseQuery(strings.TrimSpace(req.Body.Content)); err == nil {
+				if _, ok := values[name]; ok {
+					return values.Get(name), true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// queryParams returns parameter names in order of appearance
+func queryParams(query string) []string {
+	names := []string{}
+	for _, pair := range strings.FieldsFunc(query, func(r rune) bool { return r == '&' || r == ';' }) {
+		name := pair
+		if i := strings.Index(pair, "="); i >= 0 {
+			name = pair[:i]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func bodyParams(body []byte, contentType string) []string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return queryParams(string(body))
+	case strings.HasPrefix(mediaType, "multipart/"):
+		names := []string{}
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				// io.EOF or broken body, return what we have
+				return names
+			}
+			names = append(names, part.FormName())
+		}
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil
+		}
+		return jsonParams("", data)
+	case mediaType == "":
+		// w3af doesn't always keep content type, so try to guess
+		trimmed := bytes.TrimSpace(body)
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			return bodyParams(body, "application/json")
+		}
+		if bytes.Contains(body, []byte("=")) {
+			return queryParams(string(body))
+		}
+	}
+	return nil
+}
+
+// jsonParams returns paths to all json leafs like "user.name" or "items[].id"
+func jsonParams(prefix string, data interface{}) []string {
+	names := []string{}
+	switch v := data.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		// json objects are unordered in go
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			names = append(names, jsonParams(name, v[key])...)
+		}
+	case []interface{}:
+		for _, item := range v {
+			names = append(names, jsonParams(prefix+"[]", item)...)
+		}
+	default:
+		if prefix != "" {
+			names = append(names, prefix)
+		}
+	}
+	return names
+}
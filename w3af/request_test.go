@@ -0,0 +1,92 @@
+package w3af
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRequestLine(t *testing.T) {
+	method, uri, proto, ok := parseRequestLine("GET /foo?a=1 HTTP/1.1")
+	assert.True(t, ok)
+	assert.Equal(t, "GET", method)
+	assert.Equal(t, "/foo?a=1", uri)
+	assert.Equal(t, "HTTP/1.1", proto)
+
+	for _, line := range []string{"", " ", "GET", "GET /foo"} {
+		_, _, _, ok = parseRequestLine(line)
+		assert.False(t, ok, line)
+	}
+}
+
+func TestRequestUrl(t *testing.T) {
+	req := &issue.HttpEntity{
+		Status: "GET http://example.com/foo?a=1 HTTP/1.1",
+		Header: http.Header{"Host": []string{"example.com"}},
+	}
+	assert.Equal(t, "http://example.com/foo?a=1", requestUrl(req, ""))
+
+	req.Status = "GET /foo?a=1 HTTP/1.1"
+	assert.Equal(t, "http://example.com/foo?a=1", requestUrl(req, ""))
+	assert.Equal(t, "https://example.com/foo?a=1", requestUrl(req, "https://example.com/"))
+
+	req.Header = http.Header{}
+	assert.Equal(t, "https://example.org:8443/foo?a=1", requestUrl(req, "https://example.org:8443/bar"))
+	assert.Equal(t, "/foo?a=1", requestUrl(req, ""))
+
+	req.Status = ""
+	assert.Equal(t, "", requestUrl(req, ""))
+}
+
+func TestRequestParams(t *testing.T) {
+	req := &issue.HttpEntity{
+		Status: "POST /foo?a=1&b=2&a=3 HTTP/1.1",
+		Header: http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}},
+		Body:   &issue.HttpBody{ContentEncoding: "text", Content: "c=1&user%5Bname%5D=2"},
+	}
+	assert.Equal(t, []string{"a", "b", "c", "user[name]"}, requestParams(req, ""))
+	// order isn't changed by the injected parameter, it's appended if missing
+	assert.Equal(t, []string{"a", "b", "c", "user[name]"}, requestParams(req, "c"))
+	assert.Equal(t, []string{"a", "b", "c", "user[name]", "User-Agent"}, requestParams(req, "User-Agent"))
+
+	// json
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Body.Content = `{"user": {"name": "x", "age": 1}, "items": [{"id": 1}], "q": null}`
+	assert.Equal(t, []string{"a", "b", "items[].id", "q", "user.age", "user.name"}, requestParams(req, ""))
+
+	// multipart
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=XXX")
+	req.Body.Content = "--XXX\r\nContent-Disposition: form-data; name=\"title\"\r\n\r\nhello\r\n" +
+		"--XXX\r\nContent-Disposition: form-data; name=\"file\"; filename=\"a.txt\"\r\n\r\ndata\r\n--XXX--\r\n"
+	assert.Equal(t, []string{"a", "b", "title", "file"}, requestParams(req, ""))
+
+	// base64 body without content type
+	req.Header = http.Header{}
+	req.Body = &issue.HttpBody{ContentEncoding: "base64", Content: "eD0xJnk9Mg=="} // x=1&y=2
+	assert.Equal(t, []string{"a", "b", "x", "y"}, requestParams(req, ""))
+}
+
+func TestRequestParamValue(t *testing.T) {
+	req := &issue.HttpEntity{
+		Status: "POST /foo?a=1&b=%22x%22 HTTP/1.1",
+		Header: http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}},
+		Body:   &issue.HttpBody{ContentEncoding: "text", Content: "c=%3Cscript%3E&d="},
+	}
+	value, ok := requestParamValue(req, "b")
+	assert.True(t, ok)
+	assert.Equal(t, `"x"`, value)
+	value, ok = requestParamValue(req, "c")
+	assert.True(t, ok)
+	assert.Equal(t, "<script>", value)
+	value, ok = requestParamValue(req, "d")
+	assert.True(t, ok)
+	assert.Equal(t, "", value)
+	_, ok = requestParamValue(req, "e")
+	assert.False(t, ok)
+
+	req.Header.Set("Content-Type", "application/json")
+	_, ok = requestParamValue(req, "c")
+	assert.False(t, ok)
+}
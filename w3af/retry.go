@@ -0,0 +1,128 @@
+package w3af
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/bearded-web/bearded/pkg/script"
+	"golang.org/x/net/context"
+)
+
+// retryPolicy repeats failed agent calls with exponential backoff,
+// it's the only place where agent calls are retried, transports make one attempt
+type retryPolicy struct {
+	Attempts   int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	MaxElapsed time.Duration // total time of all attempts and backoffs, 0 is unlimited
+}
+
+var defaultRetryPolicy = &retryPolicy{
+	Attempts:   5,
+	MinBackoff: time.Second,
+	MaxBackoff: 30 * time.Second,
+	MaxElapsed: 5 * time.Minute,
+}
+
+// PermanentError is an error which isn't retried, e.g. the agent has answered with an error
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Recoverer is implemented by clients which know when the agent is back after failures,
+// the retry is made as soon as the agent recovers without waiting for the whole backoff
+type Recoverer interface {
+	Recovered() <-chan struct{}
+}
+
+// Do calls f until it succeeds, attempts are over or the next try doesn't fit the context deadline or MaxElapsed.
+// The context passed to f expires when MaxElapsed is over.
+func (p *retryPolicy) Do(ctx context.Context, name string, recoverer Recoverer, f func(ctx context.Context) error) error {
+	parent := ctx
+	if p.MaxElapsed > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.MaxElapsed)
+		defer cancel()
+	}
+	backoff := p.MinBackoff
+	for attempt := 1; ; attempt++ {
+		err := f(ctx)
+		if err == nil {
+			return nil
+		}
+		if parent.Err() != nil {
+			return parent.Err()
+		}
+		if permanent, ok := err.(*PermanentError); ok {
+			return permanent.Err
+		}
+		if attempt >= p.Attempts {
+			return fmt.Errorf("%s failed after %d attempts: %s", name, attempt, err)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("%s failed, no time left for the next attempt: %s", name, err)
+		}
+		logrus.WithFields(logrus.Fields{
+			"call":    name,
+			"attempt": attempt,
+			"backoff": backoff.String(),
+			"error":   err.Error(),
+		}).Warn("agent call failed, retry")
+		var recovered <-chan struct{}
+		if recoverer != nil {
+			recovered = recoverer.Recovered()
+		}
+		select {
+		case <-ctx.Done():
+			if parent.Err() != nil {
+				return parent.Err()
+			}
+			return fmt.Errorf("%s failed, no time left for the next attempt: %s", name, err)
+		case <-recovered:
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// retryClient retries downloads and reports, other calls aren't idempotent
+type retryClient struct {
+	script.ClientV1
+	policy *retryPolicy
+}
+
+// recoverer returns the wrapped client if it knows when the agent recovers
+func (c *retryClient) recoverer() Recoverer {
+	if r, ok := c.ClientV1.(Recoverer); ok {
+		return r
+	}
+	return nil
+}
+
+// DownloadFile retries failed downloads, the client should return PermanentError for agent answers
+// like a missing file, which are the same on every attempt
+func (c *retryClient) DownloadFile(ctx context.Context, fileId string) ([]byte, error) {
+	var data []byte
+	err := c.policy.Do(ctx, fmt.Sprintf("download file %s", fileId), c.recoverer(), func(ctx context.Context) error {
+		var err error
+		data, err = c.ClientV1.DownloadFile(ctx, fileId)
+		return err
+	})
+	return data, err
+}
+
+// SendReport isn't idempotent: if the agent took the report, but the answer was lost,
+// the retry delivers the report twice, so the agent should deduplicate reports.
+func (c *retryClient) SendReport(ctx context.Context, rep *report.Report) error {
+	return c.policy.Do(ctx, "send report", c.recoverer(), func(ctx context.Context) error {
+		return c.ClientV1.SendReport(ctx, rep)
+	})
+}
@@ -0,0 +1,198 @@
+package w3af
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bearded-web/bearded/models/file"
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/bearded-web/bearded/pkg/script"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+var testRetryPolicy = &retryPolicy{
+	Attempts:   3,
+	MinBackoff: time.Millisecond,
+	MaxBackoff: 2 * time.Millisecond,
+}
+
+// flakyClient runs w3af util successfully, but downloads and reports fail the first calls
+type flakyClient struct {
+	script.FakeClient
+
+	DownloadFailures int
+	ReportFailures   int
+	Permanent        bool // failures are agent answers which aren't retried
+
+	Downloads int
+	Reports   []*report.Report
+	Steps     []*plan.WorkflowStep // plugin runs
+}
+
+func (c *flakyClient) GetPlugin(ctx context.Context, name string) (*script.Plugin, error) {
+	return script.NewPlugin(name, c, "0.0.1"), nil
+}
+
+func (c *flakyClient) RunPlugin(ctx context.Context, step *plan.WorkflowStep) (*report.Report, error) {
+	c.Steps = append(c.Steps, step)
+	return &report.Report{
+		Type: report.TypeRaw,
+		Raw: report.Raw{
+			Files: []*file.Meta{&file.Meta{Id: "1", Name: step.Conf.TakeFiles[0].Name}},
+		},
+	}, nil
+}
+
+func (c *flakyClient) DownloadFile(ctx context.Context, fileId string) ([]byte, error) {
+	c.Downloads++
+	if c.Downloads <= c.DownloadFailures {
+		if c.Permanent {
+			return nil, &PermanentError{Err: fmt.Errorf("file not found")}
+		}
+		return nil, fmt.Errorf("agent is busy")
+	}
+	return loadTestData("report.xml"), nil
+}
+
+func (c *flakyClient) SendReport(ctx context.Context, rep *report.Report) error {
+	if c.ReportFailures > 0 {
+		c.ReportFailures--
+		return fmt.Errorf("agent is busy")
+	}
+	c.Reports = append(c.Reports, rep)
+	return nil
+}
+
+func TestRetryPolicy(t *testing.T) {
+	bg := context.Background()
+	calls := 0
+	err := testRetryPolicy.Do(bg, "test", nil, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("error")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// attempts are over
+	calls = 0
+	err = testRetryPolicy.Do(bg, "test", nil, func(ctx context.Context) error {
+		calls++
+		return fmt.Errorf("error")
+	})
+	require.Error(t, err)
+	assert.Equal(t, "test failed after 3 attempts: error", err.Error())
+	assert.Equal(t, 3, calls)
+
+	// the next attempt doesn't fit the deadline
+	calls = 0
+	ctx, cancel := context.WithTimeout(bg, 50*time.Millisecond)
+	defer cancel()
+	policy := &retryPolicy{Attempts: 10, MinBackoff: time.Second, MaxBackoff: time.Second}
+	started := time.Now()
+	err = policy.Do(ctx, "test", nil, func(ctx context.Context) error {
+		calls++
+		return fmt.Errorf("error")
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no time left")
+	assert.Equal(t, 1, calls)
+	assert.True(t, time.Since(started) < 50*time.Millisecond)
+
+	// cancelled context
+	ctx, cancel = context.WithCancel(bg)
+	cancel()
+	err = testRetryPolicy.Do(ctx, "test", nil, func(ctx context.Context) error { return fmt.Errorf("error") })
+	assert.Equal(t, context.Canceled, err)
+
+	// permanent errors aren't retried
+	calls = 0
+	err = testRetryPolicy.Do(bg, "test", nil, func(ctx context.Context) error {
+		calls++
+		return &PermanentError{Err: fmt.Errorf("bad report")}
+	})
+	require.Error(t, err)
+	assert.Equal(t, "bad report", err.Error())
+	assert.Equal(t, 1, calls)
+
+	// total time is bounded, a hanging attempt is cancelled
+	policy = &retryPolicy{Attempts: 10, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxElapsed: 30 * time.Millisecond}
+	calls = 0
+	started = time.Now()
+	err = policy.Do(bg, "test", nil, func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no time left")
+	assert.Equal(t, 1, calls)
+	assert.True(t, time.Since(started) < time.Second)
+}
+
+type testRecoverer chan struct{}
+
+func (r testRecoverer) Recovered() <-chan struct{} {
+	return r
+}
+
+func TestRetryPolicyRecovered(t *testing.T) {
+	recovered := make(testRecoverer)
+	close(recovered)
+	policy := &retryPolicy{Attempts: 2, MinBackoff: time.Hour, MaxBackoff: time.Hour}
+	calls := 0
+	started := time.Now()
+	err := policy.Do(context.Background(), "test", recovered, func(ctx context.Context) error {
+		calls++
+		if calls < 2 {
+			return fmt.Errorf("error")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	// the retry doesn't wait for the backoff when the agent is back
+	assert.True(t, time.Since(started) < time.Second)
+}
+
+func TestW3afHandleRetries(t *testing.T) {
+	conf := &plan.Conf{
+		Target:   "http://192.168.1.35:8082/",
+		FormData: `{"type": "plan", "data": "[audit]\nxss"}`,
+	}
+	s := newTestW3af(t)
+	s.retry = testRetryPolicy
+
+	// transient errors
+	client := &flakyClient{DownloadFailures: 2, ReportFailures: 2}
+	require.NoError(t, s.Handle(context.Background(), client, conf))
+	assert.Equal(t, 3, client.Downloads)
+	require.Len(t, client.Reports, 1)
+	assert.Equal(t, report.TypeMulti, client.Reports[0].Type)
+
+	// report is lost
+	client = &flakyClient{ReportFailures: 3}
+	err := s.Handle(context.Background(), client, conf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "send report failed after 3 attempts")
+
+	// xml report can't be downloaded
+	client = &flakyClient{DownloadFailures: 3}
+	err = s.Handle(context.Background(), client, conf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "download file 1 failed after 3 attempts")
+	assert.Len(t, client.Reports, 0)
+
+	// the agent answers with an error, the download isn't retried
+	client = &flakyClient{DownloadFailures: 3, Permanent: true}
+	err = s.Handle(context.Background(), client, conf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "file not found")
+	assert.Equal(t, 1, client.Downloads)
+	assert.Len(t, client.Reports, 0)
+}
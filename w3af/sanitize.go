@@ -0,0 +1,82 @@
+package w3af
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/bearded-web/bearded/models/plan"
+)
+
+const redactedValue = "[REDACTED]"
+
+var (
+	secretKey = regexp.MustCompile(`(?i)(pass(word|wd|phrase)?|secret|token|auth|cookie|credential|api[_-]?key|private[_-]?key|session)`)
+	// key = value lines of w3af profiles
+	secretIniLine = regexp.MustCompile(`(?im)^(\s*[\w.-]*(?:pass(?:word|wd)?|secret|token|cookie|credential|api[_-]?key)[\w.-]*\s*=\s*)\S.*$`)
+	// user:password@ in urls
+	secretUserinfo = regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`)
+)
+
+// SanitizeConf returns a copy of the conf which is safe to log: secrets in form data,
+// command args and shared files are replaced
+func SanitizeConf(conf *plan.Conf) *plan.Conf {
+	if conf == nil {
+		return nil
+	}
+	sanitized := *conf
+	sanitized.CommandArgs = sanitizeString(conf.CommandArgs)
+	sanitized.FormData = sanitizeFormData(conf.FormData)
+	sanitized.SharedFiles = nil
+	for _, f := range conf.SharedFiles {
+		copied := *f
+		copied.Text = sanitizeString(f.Text)
+		sanitized.SharedFiles = append(sanitized.SharedFiles, &copied)
+	}
+	return &sanitized
+}
+
+func sanitizeFormData(formData string) string {
+	if formData == "" {
+		return ""
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(formData), &data); err != nil {
+		// it's unknown where the secrets are
+		return fmt.Sprintf("[unparsed %d bytes]", len(formData))
+	}
+	raw, err := json.Marshal(sanitizeValue(data))
+	if err != nil {
+		return fmt.Sprintf("[unparsed %d bytes]", len(formData))
+	}
+	return string(raw)
+}
+
+// sanitizeValue replaces values of secret keys whatever their type is, e.g. lists of cookies,
+// and sanitizes strings in other values
+func sanitizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if secretKey.MatchString(key) && item != nil {
+				v[key] = redactedValue
+			} else {
+				v[key] = sanitizeValue(item)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = sanitizeValue(item)
+		}
+		return v
+	case string:
+		return sanitizeString(v)
+	}
+	return value
+}
+
+func sanitizeString(s string) string {
+	s = secretIniLine.ReplaceAllString(s, "${1}"+redactedValue)
+	return secretUserinfo.ReplaceAllString(s, "${1}"+redactedValue+"@")
+}
@@ -0,0 +1,155 @@
+package w3af
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/facebookgo/stackerr"
+)
+
+var versionRe = regexp.MustCompile(`Version:\s*(\S+)`)
+
+// scanSummary is sent with issues in the final report
+type scanSummary struct {
+	W3afVersion     string              `json:"w3afVersion,omitempty"`
+	Target          string              `json:"target"`
+	Backend         string              `json:"backend"`
+	Started         *time.Time          `json:"started,omitempty"`
+	Duration        float64             `json:"duration"` // w3af run in seconds
+	Plugins         map[string][]string `json:"plugins,omitempty"`
+	Vulnerabilities int                 `json:"vulnerabilities"`
+	BySeverity      map[string]int      `json:"bySeverity"`
+	ByPlugin        map[string]int      `json:"byPlugin"`
+	Errors          int                 `json:"errors"`
+	Dropped         int                 `json:"dropped"`
+	TruncatedBodies int                 `json:"truncatedBodies"`
+	DroppedBodies   int                 `json:"droppedBodies"` // big response bodies dropped by the redactor
+}
+
+// findingCounter is a finding processor which counts issues passed all filters
+type findingCounter struct {
+	metrics    findingMetrics
+	Total      int
+	BySeverity map[string]int
+	ByPlugin   map[string]int
+}
+
+func newFindingCounter() *findingCounter {
+	return &findingCounter{
+		metrics:    scanMetrics{},
+		BySeverity: map[string]int{},
+		ByPlugin:   map[string]int{},
+	}
+}
+
+// Process implements findingProcessor
+func (c *findingCounter) Process(vuln *Vulnerability, issueObj *issue.Issue) bool {
+	c.metrics.Issue(string(issueObj.Severity), vuln.Plugin)
+	c.Total++
+	c.BySeverity[string(issueObj.Severity)]++
+	c.ByPlugin[vuln.Plugin]++
+	return true
+}
+
+func newScanSummary(xmlReport *XmlReport, counter *findingCounter) *scanSummary {
+	s := &scanSummary{
+		W3afVersion:     w3afVersion(xmlReport.W3afVersion),
+		Vulnerabilities: len(xmlReport.Vulnerabilities),
+		BySeverity:      counter.BySeverity,
+		ByPlugin:        counter.ByPlugin,
+		Errors:          len(xmlReport.Errors),
+		Dropped:         len(xmlReport.Vulnerabilities) - counter.Total,
+	}
+	if start, err := strconv.ParseInt(xmlReport.Start, 10, 64); err == nil && start > 0 {
+		started := time.Unix(start, 0).UTC()
+		s.Started = &started
+	}
+	if xmlReport.ScanInfo != nil {
+		for _, typ := range xmlReport.ScanInfo.Types {
+			for _, plugin := range typ.Plugins {
+				if s.Plugins == nil {
+					s.Plugins = map[string][]string{}
+				}
+				s.Plugins[typ.XMLName.Local] = append(s.Plugins[typ.XMLName.Local], plugin.Name)
+			}
+		}
+	}
+	return s
+}
+
+// w3afVersion returns version from w3af-version text or the first line if there is no version
+func w3afVersion(text string) string {
+	if m := versionRe.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return strings.TrimSpace(strings.SplitN(strings.TrimSpace(text), "\n", 2)[0])
+}
+
+// Text returns human readable summary
+func (s *scanSummary) Text() string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "W3af scan summary\n\n")
+	if s.W3afVersion != "" {
+		fmt.Fprintf(buf, "W3af version: %s\n", s.W3afVersion)
+	}
+	fmt.Fprintf(buf, "Target: %s\n", s.Target)
+	fmt.Fprintf(buf, "Backend: %s\n", s.Backend)
+	if s.Started != nil {
+		fmt.Fprintf(buf, "Started: %s\n", s.Started.Format(time.RFC3339))
+	}
+	fmt.Fprintf(buf, "Duration: %s\n", time.Duration(s.Duration*float64(time.Second)).String())
+	if len(s.Plugins) > 0 {
+		fmt.Fprintf(buf, "\nEnabled plugins:\n")
+		types := make([]string, 0, len(s.Plugins))
+		for typ := range s.Plugins {
+			types = append(types, typ)
+		}
+		sort.Strings(types)
+		for _, typ := range types {
+			fmt.Fprintf(buf, "- %s: %s\n", typ, strings.Join(s.Plugins[typ], ", "))
+		}
+	}
+	fmt.Fprintf(buf, "\nVulnerabilities: %d\n", s.Vulnerabilities)
+	for _, sev := range []issue.Severity{issue.SeverityHigh, issue.SeverityMedium, issue.SeverityLow, issue.SeverityInfo,
+		issue.SeverityError} {
+		if count := s.BySeverity[string(sev)]; count > 0 {
+			fmt.Fprintf(buf, "- %s: %d\n", sev, count)
+		}
+	}
+	if len(s.ByPlugin) > 0 {
+		fmt.Fprintf(buf, "\nVulnerabilities by plugin:\n")
+		plugins := make([]string, 0, len(s.ByPlugin))
+		for plugin := range s.ByPlugin {
+			plugins = append(plugins, plugin)
+		}
+		sort.Strings(plugins)
+		for _, plugin := range plugins {
+			fmt.Fprintf(buf, "- %s: %d\n", plugin, s.ByPlugin[plugin])
+		}
+	}
+	fmt.Fprintf(buf, "\nErrors: %d\n", s.Errors)
+	fmt.Fprintf(buf, "Dropped findings: %d\n", s.Dropped)
+	fmt.Fprintf(buf, "Truncated bodies: %d\n", s.TruncatedBodies)
+	fmt.Fprintf(buf, "Dropped bodies: %d\n", s.DroppedBodies)
+	return buf.String()
+}
+
+// Reports returns summary as json and text raw reports
+func (s *scanSummary) Reports() ([]*report.Report, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	return []*report.Report{
+		&report.Report{Type: report.TypeRaw, Raw: report.Raw{Raw: string(data)}},
+		&report.Report{Type: report.TypeRaw, Raw: report.Raw{Raw: s.Text()}},
+	}, nil
+}
@@ -0,0 +1,88 @@
+package w3af
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/bearded-web/bearded/models/plan"
+	"github.com/bearded-web/bearded/models/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestScanSummary(t *testing.T) {
+	xmlReport, err := parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+	counter := newFindingCounter()
+	_, err = transformXmlReport(xmlReport, nil, 0, counter)
+	require.NoError(t, err)
+
+	s := newScanSummary(xmlReport, counter)
+	assert.Equal(t, "1.6.49", s.W3afVersion)
+	require.NotNil(t, s.Started)
+	assert.Equal(t, time.Unix(1428612319, 0).UTC(), *s.Started)
+	assert.Equal(t, []string{"xss", "sqli"}, s.Plugins["audit"])
+	assert.Equal(t, []string{"web_spider"}, s.Plugins["crawl"])
+	assert.Equal(t, 21, s.Vulnerabilities)
+	assert.Equal(t, 2, s.Errors)
+	assert.Equal(t, 21, s.BySeverity[string(issue.SeverityMedium)]+s.BySeverity[string(issue.SeverityHigh)]+
+		s.BySeverity[string(issue.SeverityLow)]+s.BySeverity[string(issue.SeverityInfo)])
+	assert.Equal(t, 0, s.Dropped)
+
+	s.Target, s.Backend, s.Duration, s.TruncatedBodies, s.DroppedBodies = "http://192.168.1.35:8082/", BackendUtil, 90, 3, 2
+	reports, err := s.Reports()
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, report.TypeRaw, reports[0].Type)
+	parsed := &scanSummary{}
+	require.NoError(t, json.Unmarshal([]byte(reports[0].Raw.Raw), parsed))
+	assert.Equal(t, s.ByPlugin, parsed.ByPlugin)
+	assert.Equal(t, 3, parsed.TruncatedBodies)
+	assert.Equal(t, 2, parsed.DroppedBodies)
+
+	text := reports[1].Raw.Raw
+	assert.Contains(t, text, "W3af version: 1.6.49\n")
+	assert.Contains(t, text, "Started: 2015-04-09T20:45:19Z\n")
+	assert.Contains(t, text, "Duration: 1m30s\n")
+	assert.Contains(t, text, "- audit: xss, sqli\n")
+	assert.Contains(t, text, "Vulnerabilities: 21\n")
+	assert.Contains(t, text, "Errors: 2\n")
+	assert.Contains(t, text, "Truncated bodies: 3\n")
+	assert.Contains(t, text, "Dropped bodies: 2\n")
+}
+
+func TestW3afVersion(t *testing.T) {
+	assert.Equal(t, "1.6.49", w3afVersion("w3af\n  Version: 1.6.49\n  Revision: 53222e5363"))
+	assert.Equal(t, "w3af 1.7", w3afVersion("\n  w3af 1.7\n  other"))
+	assert.Equal(t, "", w3afVersion(""))
+}
+
+func TestW3afHandleSummary(t *testing.T) {
+	conf := &plan.Conf{
+		Target: "http://192.168.1.35:8082/",
+		FormData: `{"type": "plan", "data": "[audit]\nxss", "suppress": {"rules": [{"url": "*/xss/reflect/basic", "reason": "test"}]},
+			"chunk": {"maxBody": 10}, "redact": {"maxResponseBody": 1000}}`,
+	}
+	s := newTestW3af(t)
+	s.retry = testRetryPolicy
+	client := &flakyClient{}
+	require.NoError(t, s.Handle(context.Background(), client, conf))
+	require.Len(t, client.Reports, 1)
+	final := client.Reports[0]
+	assert.Equal(t, report.TypeMulti, final.Type)
+	require.Len(t, final.Multi, 3)
+	assert.Equal(t, report.TypeIssues, final.Multi[0].Type)
+	summary := &scanSummary{}
+	require.NoError(t, json.Unmarshal([]byte(final.Multi[1].Raw.Raw), summary))
+	assert.Equal(t, "1.6.49", summary.W3afVersion)
+	assert.Equal(t, BackendUtil, summary.Backend)
+	assert.Equal(t, 21, summary.Vulnerabilities)
+	assert.True(t, summary.Dropped > 0)
+	assert.Equal(t, summary.Vulnerabilities-summary.Dropped, summary.ByPlugin["xss"])
+	assert.True(t, summary.TruncatedBodies > 0)
+	assert.True(t, summary.DroppedBodies > 0)
+	assert.Contains(t, final.Multi[2].Raw.Raw, "W3af scan summary")
+}
@@ -0,0 +1,202 @@
+package w3af
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
+
+// suppression actions
+const (
+	SuppressRemove    = "remove"
+	SuppressDowngrade = "downgrade"
+)
+
+// suppressRule matches findings by all set fields,
+// plugin, name, method and fingerprint are compared case insensitively
+type suppressRule struct {
+	Plugin      string `json:"plugin,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Url         string `json:"url,omitempty"`      // glob, * matches any characters
+	UrlRegex    string `json:"urlRegex,omitempty"` // regexp
+	Method      string `json:"method,omitempty"`
+	Param       string `json:"param,omitempty"` // case sensitive like parameter names in most web frameworks
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	Reason  string `json:"reason"`
+	Expires string `json:"expires,omitempty"` // 2006-01-02 or RFC3339 date
+	Action  string `json:"action,omitempty"`  // remove or downgrade, remove by default
+}
+
+type suppressConf struct {
+	Rules     []*suppressRule `json:"rules"`
+	RulesFile string          `json:"rulesFile"` // file id with json list of rules
+}
+
+type compiledRule struct {
+	*suppressRule
+	url     *regexp.Regexp
+	expires time.Time
+	expired bool
+}
+
+// suppressor removes or downgrades false positive findings by rules
+type suppressor struct {
+	rules   []*compiledRule
+	metrics findingMetrics
+
+	// number of suppressed findings by rule index
+	Suppressed map[int]int
+}
+
+func newSuppressor(rules []*suppressRule, now time.Time) (*suppressor, error) {
+	s := &suppressor{
+		metrics:    scanMetrics{},
+		Suppressed: map[int]int{},
+	}
+	for i, rule := range rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("bad suppression rule %d: %s", i+1, err)
+		}
+		compiled.expired = !compiled.expires.IsZero() && now.After(compiled.expires)
+		s.rules = append(s.rules, compiled)
+	}
+	return s, nil
+}
+
+// parseRules parses json list of suppression rules from the rules file
+func parseRules(data []byte) ([]*suppressRule, error) {
+	rules := []*suppressRule{}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func compileRule(rule *suppressRule) (*compiledRule, error) {
+	if rule == nil {
+		return nil, fmt.Errorf("rule is empty")
+	}
+	if rule.Plugin == "" && rule.Name == "" && rule.Url == "" && rule.UrlRegex == "" &&
+		rule.Method == "" && rule.Param == "" && rule.Fingerprint == "" {
+		return nil, fmt.Errorf("at least one matcher is required")
+	}
+	if rule.Reason == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+	switch rule.Action {
+	case "", SuppressRemove, SuppressDowngrade:
+	default:
+		return nil, fmt.Errorf("action should be remove or downgrade, but got %s", rule.Action)
+	}
+	if rule.Url != "" && rule.UrlRegex != "" {
+		return nil, fmt.Errorf("url and urlRegex can't be used together")
+	}
+	compiled := &compiledRule{suppressRule: rule}
+	var err error
+	if rule.Url != "" {
+		compiled.url, err = regexp.Compile(globToRegexp(rule.Url))
+	} else if rule.UrlRegex != "" {
+		compiled.url, err = regexp.Compile(rule.UrlRegex)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rule.Expires != "" {
+		if compiled.expires, err = parseDate(rule.Expires); err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
+func (r *compiledRule) Match(vuln *Vulnerability, fingerprint string) bool {
+	if r.Plugin != "" && !strings.EqualFold(r.Plugin, vuln.Plugin) {
+		return false
+	}
+	if r.Name != "" && !strings.EqualFold(r.Name, vuln.Name) {
+		return false
+	}
+	if r.url != nil && !r.url.MatchString(vuln.Url) {
+		return false
+	}
+	if r.Method != "" && !strings.EqualFold(r.Method, vuln.Method) {
+		return false
+	}
+	if r.Param != "" && r.Param != vuln.Var {
+		return false
+	}
+	if r.Fingerprint != "" && !strings.EqualFold(r.Fingerprint, fingerprint) {
+		return false
+	}
+	return true
+}
+
+// Process implements findingProcessor, the first matched rule is applied
+func (s *suppressor) Process(vuln *Vulnerability, issueObj *issue.Issue) bool {
+	fingerprint := vulnFingerprint(vuln)
+	for i, rule := range s.rules {
+		if rule.expired || !rule.Match(vuln, fingerprint) {
+			continue
+		}
+		s.Suppressed[i]++
+		if rule.Action != SuppressDowngrade {
+			s.metrics.Dropped(dropSuppressed)
+			return false
+		}
+		issueObj.Severity = issue.SeverityInfo
+		issueObj.Desc += fmt.Sprintf("\n\n###Suppressed:\n %s", rule.Reason)
+		return true
+	}
+	return true
+}
+
+// SummaryIssue returns info issue with number of suppressed findings by every rule or nil if there are no rules
+func (s *suppressor) SummaryIssue() *issue.Issue {
+	if len(s.rules) == 0 {
+		return nil
+	}
+	total := 0
+	lines := []string{}
+	for i, rule := range s.rules {
+		if rule.expired {
+			lines = append(lines, fmt.Sprintf("- rule %d (%s): expired at %s", i+1, rule.Reason, rule.Expires))
+			continue
+		}
+		total += s.Suppressed[i]
+		lines = append(lines, fmt.Sprintf("- rule %d (%s, %s): %d", i+1, rule.Reason, rule.action(), s.Suppressed[i]))
+	}
+	return &issue.Issue{
+		Severity: issue.SeverityInfo,
+		Summary:  fmt.Sprintf("%d w3af findings were suppressed", total),
+		Desc:     fmt.Sprintf("Suppressed findings by rule:\n%s", strings.Join(lines, "\n")),
+	}
+}
+
+func (r *compiledRule) action() string {
+	if r.Action == "" {
+		return SuppressRemove
+	}
+	return r.Action
+}
+
+// globToRegexp converts glob with * and ? wildcards to anchored regexp
+func globToRegexp(glob string) string {
+	re := regexp.QuoteMeta(glob)
+	re = strings.Replace(re, `\*`, ".*", -1)
+	re = strings.Replace(re, `\?`, ".", -1)
+	return "^" + re + "$"
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		// the rule works till the end of the day
+		return t.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
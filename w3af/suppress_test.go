@@ -0,0 +1,146 @@
+package w3af
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestSuppressor(t *testing.T) {
+	xmlReport, err := parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+	now := time.Date(2015, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	rules := []*suppressRule{
+		&suppressRule{
+			Plugin: "xss",
+			Url:    "http://192.168.1.35:8082/xss/reflect/js*",
+			Reason: "javascript context is escaped",
+		},
+		&suppressRule{
+			UrlRegex: `/post\d$`,
+			Method:   "post",
+			Param:    "in",
+			Reason:   "post forms are protected",
+			Action:   SuppressDowngrade,
+		},
+		&suppressRule{
+			Fingerprint: vulnFingerprint(xmlReport.Vulnerabilities[1]),
+			Reason:      "expired rule",
+			Expires:     "2015-04-30",
+		},
+		&suppressRule{
+			Name:    "Unknown vulnerability",
+			Reason:  "not matched rule",
+			Expires: "2015-05-01T10:00:00Z",
+		},
+	}
+	s, err := newSuppressor(rules, now)
+	require.NoError(t, err)
+
+	issues, err := transformXmlReport(xmlReport, nil, 0, s)
+	require.NoError(t, err)
+
+	removed := s.Suppressed[0]
+	downgraded := s.Suppressed[1]
+	assert.True(t, removed > 0)
+	assert.True(t, downgraded > 0)
+	assert.Equal(t, 0, s.Suppressed[2])
+	assert.Len(t, issues, 23-removed)
+
+	found := 0
+	for _, issueObj := range issues {
+		if issueObj.Vector == nil {
+			continue
+		}
+		assert.NotContains(t, issueObj.Vector.Url, "/xss/reflect/js")
+		if issueObj.Severity == issue.SeverityInfo {
+			assert.Contains(t, issueObj.Desc, "###Suppressed:\n post forms are protected")
+			found++
+		}
+	}
+	assert.Equal(t, downgraded, found)
+
+	summary := s.SummaryIssue()
+	require.NotNil(t, summary)
+	assert.Equal(t, issue.SeverityInfo, summary.Severity)
+	assert.Equal(t, fmt.Sprintf("%d w3af findings were suppressed", removed+downgraded), summary.Summary)
+	assert.Contains(t, summary.Desc, fmt.Sprintf("- rule 1 (javascript context is escaped, remove): %d", removed))
+	assert.Contains(t, summary.Desc, "- rule 3 (expired rule): expired at 2015-04-30")
+	assert.Contains(t, summary.Desc, "- rule 4 (not matched rule, remove): 0")
+
+	// no rules
+	s, err = newSuppressor(nil, now)
+	require.NoError(t, err)
+	assert.Nil(t, s.SummaryIssue())
+
+	// bad rules
+	badRules := []*suppressRule{
+		nil,
+		&suppressRule{Reason: "no matchers"},
+		&suppressRule{Plugin: "xss"},
+		&suppressRule{Plugin: "xss", Reason: "bad action", Action: "mute"},
+		&suppressRule{Url: "*", UrlRegex: ".*", Reason: "both urls"},
+		&suppressRule{UrlRegex: "(", Reason: "bad regex"},
+		&suppressRule{Plugin: "xss", Reason: "bad date", Expires: "tomorrow"},
+	}
+	for _, rule := range badRules {
+		_, err = newSuppressor([]*suppressRule{rule}, now)
+		assert.Error(t, err)
+	}
+}
+
+func TestSuppressRuleCase(t *testing.T) {
+	vuln := &Vulnerability{Plugin: "xss", Name: "Cross site scripting", Method: "GET", Var: "in"}
+	fingerprint := vulnFingerprint(vuln)
+
+	for _, rule := range []*suppressRule{
+		&suppressRule{Plugin: "XSS", Reason: "plugin"},
+		&suppressRule{Name: "cross SITE scripting", Reason: "name"},
+		&suppressRule{Method: "get", Reason: "method"},
+		&suppressRule{Fingerprint: strings.ToUpper(fingerprint), Reason: "fingerprint"},
+	} {
+		compiled, err := compileRule(rule)
+		require.NoError(t, err)
+		assert.True(t, compiled.Match(vuln, fingerprint), rule.Reason)
+	}
+
+	// parameter names are case sensitive
+	compiled, err := compileRule(&suppressRule{Param: "IN", Reason: "param"})
+	require.NoError(t, err)
+	assert.False(t, compiled.Match(vuln, fingerprint))
+}
+
+func TestGetSuppressor(t *testing.T) {
+	bg := context.Background()
+	client := &ClientMock{}
+	client.On("DownloadFile", bg, "rules").Return([]byte(`[{"plugin": "xss", "reason": "from file"}]`), nil).Once()
+	client.On("DownloadFile", bg, "bad").Return([]byte(`{}`), nil).Once()
+	client.On("DownloadFile", bg, "missing").Return([]byte(nil), fmt.Errorf("not found")).Once()
+
+	s, err := getSuppressor(bg, client, nil)
+	require.NoError(t, err)
+	assert.Len(t, s.rules, 0)
+
+	s, err = getSuppressor(bg, client, &suppressConf{
+		Rules:     []*suppressRule{&suppressRule{Plugin: "csrf", Reason: "from form"}},
+		RulesFile: "rules",
+	})
+	require.NoError(t, err)
+	require.Len(t, s.rules, 2)
+	assert.Equal(t, "from form", s.rules[0].Reason)
+	assert.Equal(t, "from file", s.rules[1].Reason)
+
+	_, err = getSuppressor(bg, client, &suppressConf{RulesFile: "bad"})
+	assert.Error(t, err)
+	_, err = getSuppressor(bg, client, &suppressConf{RulesFile: "missing"})
+	assert.Error(t, err)
+
+	client.Mock.AssertExpectations(t)
+}
@@ -0,0 +1,162 @@
+package w3af
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"golang.org/x/net/context"
+)
+
+const defaultProbeTimeout = 10 * time.Second
+
+var hostLabel = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]*[a-z0-9_])?$`)
+
+type targetConf struct {
+	Probe   bool `json:"probe"`   // resolve and request the target before the scan
+	Timeout int  `json:"timeout"` // probe timeout in seconds, 10 by default
+}
+
+// normalizeTarget checks the target url and returns it with lower case scheme and host,
+// without default port and with at least "/" path
+func normalizeTarget(target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", fmt.Errorf("target is empty")
+	}
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("target %q is not a valid url: %s", target, err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("target scheme should be http or https, but got %s", u.Scheme)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("target shouldn't contain credentials, use w3af auth plugins instead")
+	}
+	host, port := u.Host, ""
+	if h, p, err := net.SplitHostPort(u.Host); err == nil {
+		host, port = h, p
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if !validHost(host) {
+		return "", fmt.Errorf("target host %q is invalid", host)
+	}
+	if port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("target port %q is invalid", port)
+		}
+		if (u.Scheme == "http" && n == 80) || (u.Scheme == "https" && n == 443) {
+			port = ""
+		}
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	if port != "" {
+		u.Host = host + ":" + port
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+func validHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	for _, label := range labels {
+		if len(label) > 63 || !hostLabel.MatchString(label) {
+			return false
+		}
+	}
+	// numeric hosts like 999.1.1.1 are broken ip addresses
+	if _, err := strconv.Atoi(labels[len(labels)-1]); err == nil {
+		return false
+	}
+	return true
+}
+
+// probeTarget resolves the target host and makes one http request, any http response is fine
+func probeTarget(ctx context.Context, target string, conf *targetConf) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	timeout := defaultProbeTimeout
+	if conf.Timeout > 0 {
+		timeout = time.Duration(conf.Timeout) * time.Second
+	}
+	host := u.Host
+	if h, _, err := net.SplitHostPort(u.Host); err == nil {
+		host = h
+	}
+	if net.ParseIP(strings.Trim(host, "[]")) == nil {
+		if _, err := net.LookupHost(host); err != nil {
+			return fmt.Errorf("can't resolve target host %s: %s", host, err)
+		}
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// targets often have self-signed certificates
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return err
+	}
+	req.Cancel = ctx.Done()
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("target %s is unreachable: %s", target, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// validateTarget returns normalized target or an error if the scan shouldn't be started
+func validateTarget(ctx context.Context, target string, conf *targetConf) (string, error) {
+	target, err := normalizeTarget(target)
+	if err != nil {
+		return "", err
+	}
+	if conf != nil && conf.Probe {
+		if err := probeTarget(ctx, target, conf); err != nil {
+			return "", err
+		}
+	}
+	return target, nil
+}
+
+// invalidTargetIssue explains why the scan was skipped
+func invalidTargetIssue(err error) *issue.Issue {
+	return &issue.Issue{
+		Severity: issue.SeverityError,
+		Summary:  "Invalid w3af target, the scan was skipped",
+		Desc:     err.Error(),
+	}
+}
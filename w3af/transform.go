@@ -1,6 +1,12 @@
 package w3af
 
-import "github.com/bearded-web/bearded/models/issue"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bearded-web/bearded/models/issue"
+)
 
 // this constants from w3af/core/data/constants/severity.py
 const (
@@ -16,3 +22,121 @@ var SeverityMap = map[string]issue.Severity{
 	SevMedium: issue.SeverityMedium,
 	SevHigh:   issue.SeverityHigh,
 }
+
+// policies for vulnerabilities with unknown severity
+const (
+	UnknownDrop  = "drop"
+	UnknownInfo  = "info"
+	UnknownError = "error"
+)
+
+type severityConf struct {
+	Plugins map[string]string `json:"plugins"` // plugin name -> severity
+	Names   map[string]string `json:"names"`   // vulnerability name -> severity
+	Unknown string            `json:"unknown"` // one of drop, info, error; drop by default
+}
+
+// severityMapper maps w3af severities to issue severities
+type severityMapper struct {
+	plugins map[string]issue.Severity
+	names   map[string]issue.Severity
+	unknown string
+	metrics findingMetrics
+
+	// dropped findings by unknown severity
+	Dropped map[string]int
+}
+
+func newSeverityMapper(conf *severityConf) (*severityMapper, error) {
+	if conf == nil {
+		conf = &severityConf{}
+	}
+	m := &severityMapper{
+		plugins: map[string]issue.Severity{},
+		names:   map[string]issue.Severity{},
+		unknown: conf.Unknown,
+		metrics: scanMetrics{},
+		Dropped: map[string]int{},
+	}
+	switch m.unknown {
+	case "":
+		m.unknown = UnknownDrop
+	case UnknownDrop, UnknownInfo, UnknownError:
+	default:
+		return nil, fmt.Errorf("unknown severity policy should be one of drop, info, error, but got %s", conf.Unknown)
+	}
+	for plugin, sev := range conf.Plugins {
+		severity, ok := parseSeverity(sev)
+		if !ok {
+			return nil, fmt.Errorf("bad severity %q for plugin %s", sev, plugin)
+		}
+		m.plugins[strings.ToLower(plugin)] = severity
+	}
+	for name, sev := range conf.Names {
+		severity, ok := parseSeverity(sev)
+		if !ok {
+			return nil, fmt.Errorf("bad severity %q for vulnerability %s", sev, name)
+		}
+		m.names[strings.ToLower(name)] = severity
+	}
+	return m, nil
+}
+
+// Severity returns issue severity for the vulnerability, ok is false if the vulnerability should be dropped
+func (m *severityMapper) Severity(vuln *Vulnerability) (severity issue.Severity, ok bool) {
+	if m == nil {
+		severity, ok = SeverityMap[vuln.Severity]
+		return
+	}
+	if severity, ok = m.names[strings.ToLower(vuln.Name)]; ok {
+		return
+	}
+	if severity, ok = m.plugins[strings.ToLower(vuln.Plugin)]; ok {
+		return
+	}
+	if severity, ok = SeverityMap[vuln.Severity]; ok {
+		return
+	}
+	m.metrics.UnknownSeverity(m.unknown)
+	switch m.unknown {
+	case UnknownInfo:
+		return issue.SeverityInfo, true
+	case UnknownError:
+		return issue.SeverityError, true
+	}
+	m.Dropped[vuln.Severity]++
+	m.metrics.Dropped(dropUnknownSeverity)
+	return "", false
+}
+
+// DroppedIssue returns info issue about dropped vulnerabilities or nil if nothing is dropped
+func (m *severityMapper) DroppedIssue() *issue.Issue {
+	if m == nil || len(m.Dropped) == 0 {
+		return nil
+	}
+	total := 0
+	lines := []string{}
+	for sev, count := range m.Dropped {
+		total += count
+		lines = append(lines, fmt.Sprintf("- %q: %d", sev, count))
+	}
+	sort.Strings(lines)
+	return &issue.Issue{
+		Severity: issue.SeverityInfo,
+		Summary:  fmt.Sprintf("%d w3af findings with unknown severity were dropped", total),
+		Desc:     fmt.Sprintf("Dropped findings by severity:\n%s", strings.Join(lines, "\n")),
+	}
+}
+
+// parseSeverity accepts both w3af and issue severities
+func parseSeverity(sev string) (issue.Severity, bool) {
+	for w3afSev, severity := range SeverityMap {
+		if strings.EqualFold(sev, w3afSev) || strings.EqualFold(sev, string(severity)) {
+			return severity, true
+		}
+	}
+	if strings.EqualFold(sev, string(issue.SeverityError)) {
+		return issue.SeverityError, true
+	}
+	return "", false
+}
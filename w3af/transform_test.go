@@ -0,0 +1,89 @@
+package w3af
+
+import (
+	"testing"
+
+	"github.com/bearded-web/bearded/models/issue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeverityMapper(t *testing.T) {
+	// default behaviour
+	var m *severityMapper
+	sev, ok := m.Severity(&Vulnerability{Severity: "Medium"})
+	assert.True(t, ok)
+	assert.Equal(t, issue.SeverityMedium, sev)
+	_, ok = m.Severity(&Vulnerability{Severity: "Critical"})
+	assert.False(t, ok)
+
+	m, err := newSeverityMapper(&severityConf{
+		Plugins: map[string]string{"click_jacking": "info", "csrf": "Low"},
+		Names:   map[string]string{"Cross site scripting vulnerability": "high"},
+	})
+	require.NoError(t, err)
+
+	sev, ok = m.Severity(&Vulnerability{Plugin: "click_jacking", Severity: "Medium"})
+	assert.True(t, ok)
+	assert.Equal(t, issue.SeverityInfo, sev)
+	sev, _ = m.Severity(&Vulnerability{Plugin: "csrf", Severity: "Medium"})
+	assert.Equal(t, issue.SeverityLow, sev)
+	sev, _ = m.Severity(&Vulnerability{Plugin: "xss", Name: "cross site scripting vulnerability", Severity: "Medium"})
+	assert.Equal(t, issue.SeverityHigh, sev)
+	sev, _ = m.Severity(&Vulnerability{Plugin: "sqli", Severity: "Low"})
+	assert.Equal(t, issue.SeverityLow, sev)
+
+	assert.Nil(t, m.DroppedIssue())
+	_, ok = m.Severity(&Vulnerability{Severity: "Critical"})
+	assert.False(t, ok)
+	_, ok = m.Severity(&Vulnerability{Severity: "Critical"})
+	assert.False(t, ok)
+	_, ok = m.Severity(&Vulnerability{Severity: ""})
+	assert.False(t, ok)
+	dropped := m.DroppedIssue()
+	require.NotNil(t, dropped)
+	assert.Equal(t, issue.SeverityInfo, dropped.Severity)
+	assert.Equal(t, "3 w3af findings with unknown severity were dropped", dropped.Summary)
+	assert.Contains(t, dropped.Desc, "- \"Critical\": 2")
+
+	// unknown policies
+	m, err = newSeverityMapper(&severityConf{Unknown: UnknownInfo})
+	require.NoError(t, err)
+	sev, ok = m.Severity(&Vulnerability{Severity: "Critical"})
+	assert.True(t, ok)
+	assert.Equal(t, issue.SeverityInfo, sev)
+
+	m, err = newSeverityMapper(&severityConf{Unknown: UnknownError})
+	require.NoError(t, err)
+	sev, ok = m.Severity(&Vulnerability{Severity: "Critical"})
+	assert.True(t, ok)
+	assert.Equal(t, issue.SeverityError, sev)
+
+	// bad configs
+	_, err = newSeverityMapper(&severityConf{Unknown: "keep"})
+	assert.Error(t, err)
+	_, err = newSeverityMapper(&severityConf{Plugins: map[string]string{"csrf": "critical"}})
+	assert.Error(t, err)
+	_, err = newSeverityMapper(&severityConf{Names: map[string]string{"csrf": "critical"}})
+	assert.Error(t, err)
+}
+
+func TestTransformWithSeverities(t *testing.T) {
+	xmlReport, err := parseXml(loadTestData("report.xml"))
+	require.NoError(t, err)
+	xmlReport.Vulnerabilities[0].Severity = "Critical"
+
+	m, err := newSeverityMapper(&severityConf{Plugins: map[string]string{"xss": "low"}})
+	require.NoError(t, err)
+	issues, err := transformXmlReport(xmlReport, m, 0)
+	require.NoError(t, err)
+	// plugin mapping goes before unknown severity
+	require.Len(t, issues, 23)
+	assert.Equal(t, issue.SeverityLow, issues[2].Severity)
+
+	xmlReport.Vulnerabilities[0].Plugin = "unknown"
+	issues, err = transformXmlReport(xmlReport, m, 0)
+	require.NoError(t, err)
+	require.Len(t, issues, 22)
+	assert.Equal(t, 1, m.Dropped["Critical"])
+}
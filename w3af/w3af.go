@@ -1,142 +1,459 @@
 package w3af
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"path/filepath"
-	"strings"
-	"unicode/utf8"
+	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/bearded-web/bearded/models/issue"
 	"github.com/bearded-web/bearded/models/plan"
 	"github.com/bearded-web/bearded/models/report"
 	"github.com/bearded-web/bearded/pkg/script"
 	"github.com/facebookgo/stackerr"
+	"github.com/satori/go.uuid"
 	"golang.org/x/net/context"
 )
 
-const (
-	toolName = "barbudo/w3af"
-
-	homeDir       = "/home/app"
-	xmlReportName = "report.xml"
-)
-
 type w3afData struct {
 	Type string `json:"type"`
 	Data string `json:"data"`
+
+	Backend string   `json:"backend,omitempty"` // util or api, util by default
+	Api     *apiConf `json:"api,omitempty"`
+	Args    []string `json:"args,omitempty"` // extra w3af console flags from the allowlist, util only
+
+	Poc        *pocConf        `json:"poc,omitempty"`
+	Redact     *redactConf     `json:"redact,omitempty"`
+	Severity   *severityConf   `json:"severity,omitempty"`
+	Suppress   *suppressConf   `json:"suppress,omitempty"`
+	Group      *groupConf      `json:"group,omitempty"`
+	Diff       *diffConf       `json:"diff,omitempty"`
+	Baseline   *baselineConf   `json:"baseline,omitempty"`
+	Confidence *confidenceConf `json:"confidence,omitempty"`
+	Evidence   *evidenceConf   `json:"evidence,omitempty"`
+	Health     *healthConf     `json:"health,omitempty"`
+	Target     *targetConf     `json:"target,omitempty"`
+	Chunk      *chunkConf      `json:"chunk,omitempty"`
 }
 
 type W3af struct {
+	conf  *Config
+	retry *retryPolicy
 }
 
-func NewW3af() *W3af {
-	return &W3af{}
+// NewW3af returns w3af script with default config changed by options
+func NewW3af(opts ...Option) (*W3af, error) {
+	conf := DefaultConfig()
+	for _, opt := range opts {
+		opt(conf)
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	retry := *defaultRetryPolicy
+	if conf.RetryDeadline > 0 {
+		retry.MaxElapsed = conf.RetryDeadline
+	}
+	return &W3af{
+		conf:  conf,
+		retry: &retry,
+	}, nil
 }
 
-func (s *W3af) Handle(ctx context.Context, client script.ClientV1, conf *plan.Conf) error {
+func (s *W3af) Handle(ctx context.Context, client script.ClientV1, conf *plan.Conf) (err error) {
+	started := time.Now()
+	scansStarted.Inc()
+	defer func() {
+		if err != nil {
+			scansFailed.Inc()
+		} else {
+			scansFinished.Inc()
+		}
+	}()
+	client = &retryClient{ClientV1: client, policy: s.retry}
+	w3afData := &w3afData{}
+	if conf.FormData != "" {
+		if err := json.Unmarshal([]byte(conf.FormData), w3afData); err != nil {
+			return stackerr.Wrap(err)
+		}
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"run":     uuid.NewV4().String(),
+		"target":  sanitizeString(conf.Target), // isn't validated yet and may have credentials
+		"backend": w3afData.Backend,
+	})
+	switch w3afData.Backend {
+	case "", BackendUtil, BackendApi:
+	default:
+		return stackerr.Newf("Unknown w3af backend %s", w3afData.Backend)
+	}
+	if len(w3afData.Args) > 0 && w3afData.Backend == BackendApi {
+		return stackerr.Newf("w3af args are supported only by util backend")
+	}
+	if err := validateArgs(w3afData.Args); err != nil {
+		return stackerr.Wrap(err)
+	}
+	redactor, err := newRedactor(w3afData.Redact)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	filters, err := getScanFilters(ctx, client, w3afData)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	previousIssues, err := getPreviousIssues(ctx, client, w3afData.Diff, w3afData.Group, filters)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	target, err := validateTarget(ctx, conf.Target, w3afData.Target)
+	if err != nil {
+		log.WithFields(logrus.Fields{"phase": "validate", "error": err.Error()}).Warn("invalid target, scan is skipped")
+		return stackerr.Wrap(client.SendReport(ctx, &report.Report{
+			Type:   report.TypeIssues,
+			Issues: []*issue.Issue{invalidTargetIssue(err)},
+		}))
+	}
+	// copy conf to not change the caller's one
+	normalized := *conf
+	normalized.Target = target
+	conf = &normalized
+	log = log.WithField("target", conf.Target)
+	log.WithField("phase", "scan").Info("run w3af")
+	scanStarted := time.Now()
+	var (
+		xmlReport *XmlReport
+		logText   string
+	)
+	backend := BackendUtil
+	if w3afData.Backend == BackendApi {
+		backend = BackendApi
+		xmlReport, logText, err = runApi(ctx, log, conf, w3afData)
+	} else {
+		xmlReport, logText, err = s.runUtil(ctx, log, client, conf, w3afData)
+	}
+	runTime := time.Since(scanStarted)
+	runDuration.Observe(runTime.Seconds(), backend)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"phase":    "scan",
+			"duration": runTime.String(),
+			"error":    err.Error(),
+		}).Error("w3af failed")
+		return stackerr.Wrap(err)
+	}
+	log.WithFields(logrus.Fields{
+		"phase":           "scan",
+		"duration":        runTime.String(),
+		"vulnerabilities": len(xmlReport.Vulnerabilities),
+		"errors":          len(xmlReport.Errors),
+	}).Info("w3af finished")
+	log.WithField("phase", "transform").Debug("transform xml report")
+	processors := append([]findingProcessor{processorFunc(setFingerprint)}, filters.Processors()...)
+	var evidence *evidenceFinder
+	if w3afData.Evidence == nil || !w3afData.Evidence.Disabled {
+		evidence = newEvidenceFinder(w3afData.Evidence)
+		processors = append(processors, evidence)
+	}
+	counter := newFindingCounter()
+	processors = append(processors, counter)
+	var grouper *grouper
+	if w3afData.Group != nil && w3afData.Group.Enabled {
+		grouper = newGrouper(w3afData.Group)
+		processors = append(processors, grouper)
+	}
+	issues, err := transformXmlReport(xmlReport, filters.severities, redactor.maxResponseBody, processors...)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	if grouper != nil {
+		grouper.Finish()
+	}
+	if w3afData.Diff != nil {
+		issues = append(issues, diffIssues(issues, previousIssues)...)
+	}
+	scanLog := parseLog(logText)
+	if logText != "" {
+		issues = append(issues, scanLog.Issues()...)
+	}
+	if w3afData.Health == nil || !w3afData.Health.Disabled {
+		if reasons := checkHealth(xmlReport, scanLog, conf.Target, w3afData.Health); reasons != nil {
+			issues = append(issues, ineffectiveIssue(reasons))
+		}
+	}
+	if dropped := filters.severities.DroppedIssue(); dropped != nil {
+		issues = append(issues, dropped)
+	}
+	if suppressed := filters.suppressor.SummaryIssue(); suppressed != nil {
+		issues = append(issues, suppressed)
+	}
+	if filters.confidence != nil {
+		if filtered := filters.confidence.FilteredIssue(); filtered != nil {
+			issues = append(issues, filtered)
+		}
+	}
+	redactor.RedactIssues(issues)
+	// evidence is looked for in full redacted bodies, so matches past the truncation limit are found too
+	if evidence != nil {
+		for _, issueObj := range issues {
+			evidence.addEvidence(issueObj)
+		}
+	}
+	addReproduce(issues, w3afData.Poc)
+	summary := newScanSummary(xmlReport, counter)
+	summary.Target, summary.Backend, summary.Duration = conf.Target, backend, runTime.Seconds()
+	summary.TruncatedBodies = truncateIssues(issues, w3afData.Chunk)
+	fitted, err := fitIssues(issues, w3afData.Chunk)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	summary.TruncatedBodies += fitted
+	summary.DroppedBodies = redactor.DroppedBodies
+	summaryReports, err := summary.Reports()
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	resultReport := &report.Report{Type: report.TypeMulti, Multi: summaryReports}
+	if len(issues) > 0 {
+		issuesReport := &report.Report{Type: report.TypeIssues, Issues: issues}
+		resultReport.Multi = append([]*report.Report{issuesReport}, summaryReports...)
+	}
+	// push reports, big reports are split to fit transport message limits
+	chunks, err := chunkReport(resultReport, w3afData.Chunk)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	for i, chunk := range chunks {
+		if err := client.SendReport(ctx, chunk); err != nil {
+			log.WithFields(logrus.Fields{
+				"phase":  "send",
+				"chunk":  i + 1,
+				"chunks": len(chunks),
+				"error":  err.Error(),
+			}).Error("report wasn't sent")
+			return stackerr.Newf("report chunk %d of %d wasn't sent: %s", i+1, len(chunks), err)
+		}
+	}
+	fields := severityCounts(issues)
+	fields["phase"] = "send"
+	fields["issues"] = len(issues)
+	fields["chunks"] = len(chunks)
+	fields["droppedBodies"] = redactor.DroppedBodies
+	fields["duration"] = time.Since(started).String()
+	log.WithFields(fields).Info("report is sent")
+	return nil
+}
+
+// runUtil runs w3af console as a plugin and returns parsed xml report and console output
+func (s *W3af) runUtil(ctx context.Context, log *logrus.Entry, client script.ClientV1, conf *plan.Conf,
+	w3afData *w3afData) (*XmlReport, string, error) {
 	// Check if plugin is available
-	println("get tool")
+	log.WithField("tool", s.conf.Tool).Debug("get tool")
 	pl, err := s.getTool(ctx, client)
 	if err != nil {
-		return err
+		return nil, "", err
+	}
+	version := s.conf.pluginVersion(pl)
+	if version == "" {
+		return nil, "", stackerr.Newf("%s has no version matching %q, available versions: %v",
+			s.conf.Tool, s.conf.Version, pl.Versions)
 	}
-	xmlOutputPath := filepath.Join(homeDir, xmlReportName)
+	xmlOutputPath := s.conf.ReportPath()
 	p := &plan.Conf{
 		TakeFiles: []*plan.File{
 			&plan.File{
 				Path: xmlOutputPath,
-				Name: xmlReportName,
+				Name: s.conf.ReportName,
 			},
 		},
 	}
-
-	w3afData := &w3afData{}
-	if conf.FormData != "" {
-		if err = json.Unmarshal([]byte(conf.FormData), w3afData); err != nil {
-			return stackerr.Wrap(err)
-		}
-	}
+	args := []string{}
 	if w3afData.Type == "plan" {
 		profile := Profile{
 			Base:          w3afData.Data,
 			Target:        conf.Target,
 			XmlOutputPath: xmlOutputPath,
 		}
-		p.CommandArgs = "-P /share/profile.pw3af"
+		args = append(args, "-P", s.conf.ProfilePath())
 		p.SharedFiles = []*plan.SharedFile{
 			&plan.SharedFile{
-				Path: "profile.pw3af",
+				Path: s.conf.ProfileName,
 				Text: profile.GenIni(),
 			},
 		}
 
 	}
-	println("run w3af")
+	args = append(args, s.conf.ExtraArgs...)
+	p.CommandArgs = quoteArgs(append(args, w3afData.Args...))
 	// Run w3af util
-	rep, err := pl.Run(ctx, pl.LatestVersion(), p)
+	log.WithField("version", version).Debug("run tool")
+	rep, err := pl.Run(ctx, version, p)
 	if err != nil {
-		return stackerr.Wrap(err)
+		return nil, "", stackerr.Wrap(err)
 	}
-	println("w3af finished")
 	// Get and parse w3af output
 	if rep.Type != report.TypeRaw {
-		return stackerr.Newf("W3af report type should be TypeRaw, but got %s instead", rep.Type)
+		return nil, "", stackerr.Newf("W3af report type should be TypeRaw, but got %s instead", rep.Type)
 	}
-	resultReport := report.Report{Type: report.TypeEmpty}
-	println("get xml report")
-	xmlReport, err := getXmlReport(ctx, client, rep)
+	log.WithField("files", len(rep.Raw.Files)).Debug("get xml report")
+	xmlReport, err := getXmlReport(ctx, client, rep, s.conf.ReportName)
 	if err != nil {
-		return stackerr.Wrap(err)
+		return nil, "", err
 	}
-	println("transofrm xml report")
-	issues, err := transformXmlReport(xmlReport)
+	return xmlReport, rep.Raw.Raw, nil
+}
+
+// runApi runs the scan through w3af REST API
+func runApi(ctx context.Context, log *logrus.Entry, conf *plan.Conf, w3afData *w3afData) (*XmlReport, string, error) {
+	api, err := newApiClient(w3afData.Api)
 	if err != nil {
-		return stackerr.Wrap(err)
+		return nil, "", err
 	}
-	if len(issues) > 0 {
-		resultReport.Type = report.TypeIssues
-		resultReport.Issues = issues
-	}
-	// push reports
-	client.SendReport(ctx, &resultReport)
-	//	spew.Dump(resultReport)
-	println("sent")
-	// exit
-	return nil
+	profile := Profile{
+		Target: conf.Target,
+	}
+	if w3afData.Type == "plan" {
+		profile.Base = w3afData.Data
+	}
+	log.WithField("api", api.url).Debug("start scan")
+	xmlReport, logText, err := api.Scan(ctx, log, profile.GenIni(), []string{conf.Target})
+	if err != nil {
+		return nil, "", err
+	}
+	return xmlReport, logText, nil
 }
 
 // Check if w3af plugin is available
 func (s *W3af) getTool(ctx context.Context, client script.ClientV1) (*script.Plugin, error) {
-	pl, err := client.GetPlugin(ctx, toolName)
+	pl, err := client.GetPlugin(ctx, s.conf.Tool)
 	if err != nil {
 		return nil, err
 	}
 	return pl, err
 }
 
-func getXmlReport(ctx context.Context, client script.ClientV1, rep *report.Report) (*XmlReport, error) {
+func getXmlReport(ctx context.Context, client script.ClientV1, rep *report.Report, reportName string) (*XmlReport, error) {
 	reportXmlId := ""
 	if rep.Files != nil {
 		for _, f := range rep.Files {
-			if f.Name == xmlReportName {
+			if f.Name == reportName {
 				reportXmlId = f.Id
 			}
 		}
 	}
 	if reportXmlId == "" {
-		return nil, fmt.Errorf("report.xml is required for w3af-script")
+		return nil, fmt.Errorf("%s is required for w3af-script", reportName)
 	}
 	reportXmlData, err := client.DownloadFile(ctx, reportXmlId)
 	if err != nil {
 		return nil, stackerr.Wrap(err)
 	}
+	reportXmlSize.Observe(float64(len(reportXmlData)))
+	parseStarted := time.Now()
+	defer func() { parseDuration.Observe(time.Since(parseStarted).Seconds()) }()
 	return parseXml(reportXmlData)
 }
 
-func transformXmlReport(xmlRep *XmlReport) ([]*issue.Issue, error) {
+// findingProcessor is applied to every issue made from vulnerability,
+// the issue is dropped if Process returns false
+type findingProcessor interface {
+	Process(vuln *Vulnerability, issueObj *issue.Issue) bool
+}
+
+// processorFunc is an adapter to use ordinary functions as finding processors
+type processorFunc func(vuln *Vulnerability, issueObj *issue.Issue) bool
+
+func (f processorFunc) Process(vuln *Vulnerability, issueObj *issue.Issue) bool {
+	return f(vuln, issueObj)
+}
+
+// scanFilters drop or downgrade findings by the scan settings
+type scanFilters struct {
+	severities *severityMapper
+	suppressor *suppressor
+	baseline   *baselineFilter
+	confidence *confidenceScorer
+}
+
+// getScanFilters makes filters from form data, rules and baseline files are downloaded
+func getScanFilters(ctx context.Context, client script.ClientV1, w3afData *w3afData) (*scanFilters, error) {
+	var err error
+	filters := &scanFilters{}
+	if filters.severities, err = newSeverityMapper(w3afData.Severity); err != nil {
+		return nil, err
+	}
+	if filters.suppressor, err = getSuppressor(ctx, client, w3afData.Suppress); err != nil {
+		return nil, err
+	}
+	if filters.baseline, err = getBaselineFilter(ctx, client, w3afData.Baseline); err != nil {
+		return nil, err
+	}
+	if w3afData.Confidence == nil || !w3afData.Confidence.Disabled {
+		if filters.confidence, err = newConfidenceScorer(w3afData.Confidence); err != nil {
+			return nil, err
+		}
+	}
+	return filters, nil
+}
+
+// Processors returns filters in the order they are applied after the fingerprint
+func (f *scanFilters) Processors() []findingProcessor {
+	processors := []findingProcessor{f.suppressor}
+	if f.baseline != nil {
+		processors = append(processors, f.baseline)
+	}
+	if f.confidence != nil {
+		processors = append(processors, f.confidence)
+	}
+	return processors
+}
+
+// Fresh returns filters with the same settings, zero counters and the metrics
+func (f *scanFilters) Fresh(metrics findingMetrics) *scanFilters {
+	fresh := &scanFilters{
+		severities: &severityMapper{
+			plugins: f.severities.plugins,
+			names:   f.severities.names,
+			unknown: f.severities.unknown,
+			metrics: metrics,
+			Dropped: map[string]int{},
+		},
+		suppressor: &suppressor{rules: f.suppressor.rules, metrics: metrics, Suppressed: map[int]int{}},
+	}
+	if f.baseline != nil {
+		fresh.baseline = &baselineFilter{mode: f.baseline.mode, findings: f.baseline.findings, metrics: metrics}
+	}
+	if f.confidence != nil {
+		fresh.confidence = &confidenceScorer{min: f.confidence.min, metrics: metrics}
+	}
+	return fresh
+}
+
+// getSuppressor makes suppressor with rules from form data and the rules file
+func getSuppressor(ctx context.Context, client script.ClientV1, conf *suppressConf) (*suppressor, error) {
+	rules := []*suppressRule{}
+	if conf != nil {
+		rules = append(rules, conf.Rules...)
+		if conf.RulesFile != "" {
+			data, err := client.DownloadFile(ctx, conf.RulesFile)
+			if err != nil {
+				return nil, stackerr.Wrap(err)
+			}
+			fileRules, err := parseRules(data)
+			if err != nil {
+				return nil, stackerr.Wrap(err)
+			}
+			rules = append(rules, fileRules...)
+		}
+	}
+	return newSuppressor(rules, time.Now())
+}
+
+// transformXmlReport makes issues from w3af findings, compressed http bodies bigger than maxBody
+// when decompressed are kept as is, see decodeBody
+func transformXmlReport(xmlRep *XmlReport, severities *severityMapper, maxBody int,
+	processors ...findingProcessor) ([]*issue.Issue, error) {
 	issues := []*issue.Issue{}
 	for _, xmlErr := range xmlRep.Errors {
 		issue := &issue.Issue{
@@ -147,7 +464,7 @@ func transformXmlReport(xmlRep *XmlReport) ([]*issue.Issue, error) {
 		issues = append(issues, issue)
 	}
 	for _, vuln := range xmlRep.Vulnerabilities {
-		severity, ok := SeverityMap[vuln.Severity]
+		severity, ok := severities.Severity(vuln)
 		if !ok {
 			continue
 		}
@@ -180,75 +497,65 @@ func transformXmlReport(xmlRep *XmlReport) ([]*issue.Issue, error) {
 					Method: vuln.Method,
 				}
 				if trans.Request != nil {
-					httpTran.Request = transformHttpEntity(trans.Request)
-					if _, requestUrl, _, ok := parseRequestLine(httpTran.Request.Status); ok {
-						httpTran.Url = requestUrl
-					}
+					httpTran.Request = transformHttpEntity(trans.Request, maxBody)
+					httpTran.Url = requestUrl(httpTran.Request, vuln.Url)
+					httpTran.Params = requestParams(httpTran.Request, vuln.Var)
+				} else if len(vuln.Var) > 0 {
+					httpTran.Params = append(httpTran.Params, vuln.Var)
 				}
 				if trans.Response != nil {
-					httpTran.Response = transformHttpEntity(trans.Response)
-				}
-				if len(vuln.Var) > 0 {
-					httpTran.Params = append(httpTran.Params, vuln.Var)
+					httpTran.Response = transformHttpEntity(trans.Response, maxBody)
 				}
 				transactions = append(transactions, httpTran)
 			}
 			issueObj.Vector.HttpTransactions = transactions
 		}
+		if len(vuln.Var) > 0 {
+			issueObj.Extras = append(issueObj.Extras, injectedParamExtra(vuln.Var))
+		}
+		if !process(processors, vuln, issueObj) {
+			continue
+		}
 		issues = append(issues, issueObj)
 	}
 	return issues, nil
 }
 
-func transformHttpEntity(ent *HttpEntity) *issue.HttpEntity {
-	// TODO (m0sth8): extract url from status
+func process(processors []findingProcessor, vuln *Vulnerability, issueObj *issue.Issue) bool {
+	for _, p := range processors {
+		if !p.Process(vuln, issueObj) {
+			return false
+		}
+	}
+	return true
+}
+
+func transformHttpEntity(ent *HttpEntity, maxBody int) *issue.HttpEntity {
 	out := &issue.HttpEntity{
 		Status: ent.Status,
 		Header: http.Header{},
 	}
-	if ent.Body != nil {
-		contentEncoding := ent.Body.ContentEncoding
-		var body string
-		switch contentEncoding {
-		case "base64":
-			// decode base64 if it has valid utf-8 content
-			if utf8.Valid(ent.Body.Content) {
-				buf := make([]byte, base64.StdEncoding.DecodedLen(len(ent.Body.Content)))
-				if n, err := base64.StdEncoding.Decode(buf, ent.Body.Content); err != nil {
-					body = string(ent.Body.Content[:n])
-				} else {
-					body = string(buf)
-					contentEncoding = "text"
-				}
-			} else {
-				body = string(ent.Body.Content)
-			}
-		case "text":
-			body = string(ent.Body.Content)
-		default:
-			body = fmt.Sprintf("%s content encoding isn't supported yet", ent.Body.ContentEncoding)
-
-		}
-		out.Body = &issue.HttpBody{
-			ContentEncoding: contentEncoding,
-			Content:         body,
-		}
-	}
 	if ent.Headers != nil && len(ent.Headers) > 0 {
 		for _, h := range ent.Headers {
 			out.Header.Add(h.Field, h.Content)
 		}
 	}
+	if ent.Body != nil {
+		out.Body = decodeBody(ent.Body, out.Header, maxBody)
+	}
 	return out
 }
 
-// parseRequestLine parses "GET /foo HTTP/1.1" into its three parts.
-func parseRequestLine(line string) (method, requestURI, proto string, ok bool) {
-	s1 := strings.Index(line, " ")
-	s2 := strings.Index(line[s1+1:], " ")
-	if s1 < 0 || s2 < 0 {
-		return
+// severityCounts returns number of issues by severity as log fields
+func severityCounts(issues []*issue.Issue) logrus.Fields {
+	fields := logrus.Fields{}
+	for _, issueObj := range issues {
+		key := string(issueObj.Severity)
+		if count, ok := fields[key].(int); ok {
+			fields[key] = count + 1
+		} else {
+			fields[key] = 1
+		}
 	}
-	s2 += s1 + 1
-	return line[:s1], line[s1+1 : s2], line[s2+1:], true
+	return fields
 }
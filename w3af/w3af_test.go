@@ -19,6 +19,8 @@ import (
 type ClientMock struct {
 	mock.Mock
 	*script.FakeClient
+
+	Reports []*report.Report // sent reports
 }
 
 func (m *ClientMock) GetPlugin(ctx context.Context, name string) (*script.Plugin, error) {
@@ -36,6 +38,18 @@ func (m *ClientMock) DownloadFile(ctx context.Context, fileId string) ([]byte, e
 
 }
 
+func (m *ClientMock) SendReport(ctx context.Context, rep *report.Report) error {
+	m.Reports = append(m.Reports, rep)
+	args := m.Called(ctx, rep)
+	return args.Error(0)
+}
+
+func newTestW3af(t *testing.T, opts ...Option) *W3af {
+	s, err := NewW3af(opts...)
+	require.NoError(t, err)
+	return s
+}
+
 func TestW3afGetXmlReport(t *testing.T) {
 	bg := context.Background()
 
@@ -57,7 +71,7 @@ func TestW3afGetXmlReport(t *testing.T) {
 			},
 		},
 	}
-	xmlRep, err := getXmlReport(bg, client, rep)
+	xmlRep, err := getXmlReport(bg, client, rep, "report.xml")
 	require.NoError(t, err)
 	require.NotNil(t, xmlRep)
 	expected, err := parseXml(reportXmlData)
@@ -67,18 +81,18 @@ func TestW3afGetXmlReport(t *testing.T) {
 	// errors
 	// download file returned error
 	rep.Raw.Files[0].Id = "2"
-	_, err = getXmlReport(bg, client, rep)
+	_, err = getXmlReport(bg, client, rep, "report.xml")
 	assert.Error(t, err)
 
 	// bad xml data
 	rep.Raw.Files[0].Id = "3"
-	_, err = getXmlReport(bg, client, rep)
+	_, err = getXmlReport(bg, client, rep, "report.xml")
 	require.Error(t, err)
 
 	// report.xml is not existed
 	rep.Raw.Files[0].Id = "1"
 	rep.Raw.Files[0].Name = "report.yaml"
-	_, err = getXmlReport(bg, client, rep)
+	_, err = getXmlReport(bg, client, rep, "report.xml")
 	require.Error(t, err)
 
 	client.Mock.AssertExpectations(t)
@@ -93,7 +107,7 @@ func TestW3afTransform(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, xmlReport)
 
-	issues, err := transformXmlReport(xmlReport)
+	issues, err := transformXmlReport(xmlReport, nil, 0)
 	//	data, err := json.Marshal(issues)
 	//	println(string(data))
 	require.NoError(t, err)